@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigName is the project config, discovered upward from the working directory.
+const projectConfigName = ".dump.yaml"
+
+// configFile is a parsed config: top-level settings plus named profiles. Settings
+// map long flag names to values; list values are used for repeatable flags.
+type configFile struct {
+	path     string
+	settings map[string]any
+	profiles map[string]map[string]any
+}
+
+// configLayer is one set of settings. Later layers take precedence.
+type configLayer struct {
+	name     string // user, project or profile <name>
+	path     string
+	settings map[string]any
+}
+
+// userConfigPath returns $XDG_CONFIG_HOME/dump/config.yaml, falling back to ~/.config.
+func userConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "dump", "config.yaml")
+}
+
+// findProjectConfig returns the nearest .dump.yaml in dir or one of its parents.
+func findProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readConfigFile parses path, returning nil if it does not exist.
+func readConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	cfg := &configFile{path: path, settings: raw, profiles: make(map[string]map[string]any)}
+	if cfg.settings == nil {
+		cfg.settings = make(map[string]any)
+	}
+	if p, ok := cfg.settings["profiles"]; ok {
+		delete(cfg.settings, "profiles")
+		profiles, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid profiles in %s (must be a mapping of names to settings)", path)
+		}
+		for name, v := range profiles {
+			settings, ok := v.(map[string]any)
+			if !ok && v != nil {
+				return nil, fmt.Errorf("invalid profile %q in %s (must be a mapping of settings)", name, path)
+			}
+			cfg.profiles[name] = settings
+		}
+	}
+	return cfg, nil
+}
+
+// loadConfig reads the user and project configs and returns their layers in
+// increasing precedence: user, project, then the named profile (if any). A profile
+// defined in both configs is merged, the project's settings winning.
+func loadConfig(flags *pflag.FlagSet, profile string) ([]configLayer, error) {
+	var files []*configFile
+	var names []string
+	if path := userConfigPath(); path != "" {
+		cfg, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if cfg != nil {
+			files = append(files, cfg)
+			names = append(names, "user")
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if path := findProjectConfig(wd); path != "" {
+			cfg, err := readConfigFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if cfg != nil {
+				files = append(files, cfg)
+				names = append(names, "project")
+			}
+		}
+	}
+
+	var layers, profileLayers []configLayer
+	for i, cfg := range files {
+		if err := checkSettings(flags, cfg.settings, cfg.path); err != nil {
+			return nil, err
+		}
+		if names[i] == "project" {
+			if err := checkProjectSettings(cfg.settings, cfg.path); err != nil {
+				return nil, err
+			}
+		}
+		for name, settings := range cfg.profiles {
+			where := fmt.Sprintf("%s (profile %q)", cfg.path, name)
+			if err := checkSettings(flags, settings, where); err != nil {
+				return nil, err
+			}
+			if names[i] == "project" {
+				if err := checkProjectSettings(settings, where); err != nil {
+					return nil, err
+				}
+			}
+		}
+		layers = append(layers, configLayer{name: names[i], path: cfg.path, settings: cfg.settings})
+		if settings, ok := cfg.profiles[profile]; ok && profile != "" {
+			profileLayers = append(profileLayers, configLayer{name: "profile " + profile, path: cfg.path, settings: settings})
+		}
+	}
+	if profile != "" && len(profileLayers) == 0 {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+	return append(layers, profileLayers...), nil
+}
+
+// checkSettings rejects settings that do not name a dump flag.
+func checkSettings(flags *pflag.FlagSet, settings map[string]any, where string) error {
+	for key := range settings {
+		if flags.Lookup(key) == nil || key == "help" || key == "version" || key == "profile" {
+			return fmt.Errorf("unknown setting %q in %s", key, where)
+		}
+	}
+	return nil
+}
+
+// projectDenied are the settings a project config cannot hold. The config comes
+// with the checked-out code, so it must not make a plain `dump` run commands,
+// fetch URLs, read panes or files outside the project, or write files.
+var projectDenied = map[string]bool{
+	"cmd": true, "tmux": true, "url": true, "dir": true, "files-from": true,
+	"output": true, "out-dir": true, "copy": true, "snapshot": true,
+}
+
+// checkProjectSettings rejects the settings of a project config that only the
+// user may choose: projectDenied, and turning redaction off.
+func checkProjectSettings(settings map[string]any, where string) error {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if projectDenied[key] {
+			return fmt.Errorf("setting %q is not allowed in a project config (%s); pass it on the command line or set it in the user config", key, where)
+		}
+	}
+	if fmt.Sprint(settings["redact"]) == "off" {
+		return fmt.Errorf("redact: off is not allowed in a project config (%s); pass --redact=off on the command line or set it in the user config", where)
+	}
+	return nil
+}
+
+// settingValues converts a config value to the strings passed to a flag's Set.
+func settingValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		var values []string
+		for _, e := range v {
+			switch e.(type) {
+			case []any, map[string]any:
+				return nil, fmt.Errorf("list entries must be scalars")
+			}
+			values = append(values, fmt.Sprint(e))
+		}
+		return values, nil
+	case map[string]any:
+		return nil, fmt.Errorf("must be a scalar or a list")
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// isRepeatable reports whether f accepts a list of values.
+func isRepeatable(f *pflag.Flag) bool {
+	return strings.HasSuffix(f.Value.Type(), "Array") || strings.HasSuffix(f.Value.Type(), "Slice")
+}
+
+// applyConfig sets every flag that was not given on the command line from the
+// config layers, the last layer that sets a flag winning. It returns the layer
+// each applied setting came from. skip lists flags to leave alone.
+func applyConfig(flags *pflag.FlagSet, layers []configLayer, skip ...string) (map[string]configLayer, error) {
+	origins := make(map[string]configLayer)
+	for _, layer := range layers {
+		for key := range layer.settings {
+			origins[key] = layer
+		}
+	}
+	for _, key := range skip {
+		delete(origins, key)
+	}
+
+	// apply in name order so repeated runs record source flags identically
+	keys := make([]string, 0, len(origins))
+	for key := range origins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f := flags.Lookup(key)
+		if f.Changed {
+			delete(origins, key)
+			continue
+		}
+		layer := origins[key]
+		values, err := settingValues(layer.settings[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q in %s: %w", key, layer.path, err)
+		}
+		if len(values) > 1 && !isRepeatable(f) {
+			return nil, fmt.Errorf("invalid %q in %s: takes a single value", key, layer.path)
+		}
+		for _, v := range values {
+			// set the value directly so Changed still means "given on the command line"
+			if err := f.Value.Set(v); err != nil {
+				return nil, fmt.Errorf("invalid %q in %s: %w", key, layer.path, err)
+			}
+		}
+	}
+	return origins, nil
+}
+
+// formatSettings renders the current value of every flag as YAML, with a comment
+// naming where each configured value came from.
+func formatSettings(flags *pflag.FlagSet, layers []configLayer, origins map[string]configLayer) (string, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" || f.Name == "version" || f.Name == "profile" {
+			return
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}
+		var value *yaml.Node
+		if isRepeatable(f) {
+			// comma-separated lists (--meta) are string slices, the rest arrays
+			get := flags.GetStringArray
+			if f.Value.Type() == "stringSlice" {
+				get = flags.GetStringSlice
+			}
+			values, _ := get(f.Name)
+			value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, v := range values {
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+			}
+		} else {
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: f.Value.String()}
+			switch f.Value.Type() {
+			case "bool":
+				value.Tag = "!!bool"
+			case "int":
+				value.Tag = "!!int"
+			default:
+				value.Tag = "!!str"
+			}
+		}
+		if layer, ok := origins[f.Name]; ok {
+			value.LineComment = layer.name
+		}
+		doc.Content = append(doc.Content, key, value)
+	})
+
+	var sb strings.Builder
+	for _, layer := range layers {
+		fmt.Fprintf(&sb, "# %s: %s\n", layer.name, layer.path)
+	}
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect dump configuration",
+	Long: `dump reads defaults from a user config ($XDG_CONFIG_HOME/dump/config.yaml) and a project .dump.yaml,
+discovered upward from the current directory. settings are named after the long flags; named profiles
+under "profiles" are selected with -p. precedence: command line > profile > project > user.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective settings",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showConfig(cmd.OutOrStdout())
+	},
+}
+
+// showConfig applies the config to the root flags and prints the result.
+func showConfig(w io.Writer) error {
+	flags := rootCmd.Flags()
+	layers, err := loadConfig(flags, profile)
+	if err != nil {
+		return err
+	}
+	origins, err := applyConfig(flags, layers)
+	if err != nil {
+		return err
+	}
+	out, err := formatSettings(flags, layers, origins)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// testFlags is a small stand-in for the dump flags.
+type testFlags struct {
+	set       *pflag.FlagSet
+	format    string
+	exts      []string
+	meta      []string
+	tree      bool
+	maxTokens int
+}
+
+func newTestFlags(t *testing.T, args ...string) *testFlags {
+	t.Helper()
+	f := &testFlags{set: pflag.NewFlagSet("dump", pflag.ContinueOnError)}
+	f.set.StringVarP(&f.format, "out-fmt", "o", "xml", "")
+	f.set.StringArrayVarP(&f.exts, "ext", "e", nil, "")
+	f.set.StringSliceVar(&f.meta, "meta", nil, "")
+	f.set.BoolVarP(&f.tree, "tree", "t", false, "")
+	f.set.IntVar(&f.maxTokens, "max-tokens", 0, "")
+	f.set.BoolP("help", "h", false, "")
+	if err := f.set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withConfigDirs points the user config at a temp XDG_CONFIG_HOME and runs from a
+// subdirectory of a temp project, returning the user and project config paths.
+func withConfigDirs(t *testing.T) (userPath, projectPath string) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "xdg"))
+	project := filepath.Join(root, "project")
+	sub := filepath.Join(project, "src", "pkg")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Join(root, "xdg", "dump", "config.yaml"), filepath.Join(project, projectConfigName)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	userPath, projectPath := withConfigDirs(t)
+	writeConfig(t, userPath, `
+out-fmt: md
+max-tokens: 1000
+tree: true
+profiles:
+  review:
+    max-tokens: 5000
+  mine:
+    out-fmt: json
+`)
+	writeConfig(t, projectPath, `
+ext: [go, md]
+out-fmt: jsonl
+profiles:
+  review:
+    ext: go
+`)
+
+	testCases := []struct {
+		name      string
+		args      []string
+		profile   string
+		format    string
+		exts      []string
+		tree      bool
+		maxTokens int
+	}{
+		{"Project over user", nil, "", "jsonl", []string{"go", "md"}, true, 1000},
+		{"Profile over project", nil, "review", "jsonl", []string{"go"}, true, 5000},
+		{"User profile over project", nil, "mine", "json", []string{"go", "md"}, true, 1000},
+		{"Command line over profile", []string{"-e", "txt", "-o", "xml", "--max-tokens", "0"}, "review", "xml", []string{"txt"}, true, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newTestFlags(t, tc.args...)
+			layers, err := loadConfig(f.set, tc.profile)
+			if err != nil {
+				t.Fatalf("loadConfig: %v", err)
+			}
+			if _, err := applyConfig(f.set, layers); err != nil {
+				t.Fatalf("applyConfig: %v", err)
+			}
+			if f.format != tc.format || !reflect.DeepEqual(f.exts, tc.exts) || f.tree != tc.tree || f.maxTokens != tc.maxTokens {
+				t.Errorf("Got out-fmt=%q ext=%q tree=%v max-tokens=%d, expected out-fmt=%q ext=%q tree=%v max-tokens=%d",
+					f.format, f.exts, f.tree, f.maxTokens, tc.format, tc.exts, tc.tree, tc.maxTokens)
+			}
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		profile  string
+		expected string
+	}{
+		{"Unknown setting", "exts: [go]\n", "", `unknown setting "exts"`},
+		{"Unknown setting in profile", "profiles:\n  p:\n    bogus: 1\n", "", `unknown setting "bogus"`},
+		{"Unknown profile", "tree: true\n", "nope", `unknown profile "nope"`},
+		{"List for single value", "out-fmt: [md, xml]\n", "", "takes a single value"},
+		{"Invalid value", "max-tokens: lots\n", "", `invalid "max-tokens"`},
+		{"Invalid profiles", "profiles: [a]\n", "", "invalid profiles"},
+		{"Reserved flag", "help: true\n", "", `unknown setting "help"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, projectPath := withConfigDirs(t)
+			writeConfig(t, projectPath, tc.config)
+			f := newTestFlags(t)
+			layers, err := loadConfig(f.set, tc.profile)
+			if err == nil {
+				_, err = applyConfig(f.set, layers)
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestProjectConfigRestrictions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		user     string
+		project  string
+		profile  string
+		expected string
+	}{
+		{"Command", "", "cmd: [make]\n", "", `setting "cmd" is not allowed in a project config`},
+		{"Output in a profile", "", "profiles:\n  p:\n    output: out.xml\n", "", `setting "output" is not allowed in a project config`},
+		{"Redaction off", "", "redact: off\n", "", "redact: off is not allowed in a project config"},
+		{"Redaction fail", "", "redact: fail\n", "", ""},
+		{"User config", "cmd: [make]\nredact: off\noutput: out.xml\n", "tree: true\n", "", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userPath, projectPath := withConfigDirs(t)
+			if tc.user != "" {
+				writeConfig(t, userPath, tc.user)
+			}
+			writeConfig(t, projectPath, tc.project)
+			f := newTestFlags(t)
+			f.set.StringArray("cmd", nil, "")
+			f.set.String("output", "", "")
+			f.set.String("redact", "mask", "")
+			_, err := loadConfig(f.set, tc.profile)
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("loadConfig: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestApplyConfigSkip(t *testing.T) {
+	f := newTestFlags(t)
+	layers := []configLayer{{name: "project", settings: map[string]any{"ext": []any{"go"}, "tree": true}}}
+	origins, err := applyConfig(f.set, layers, "ext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.exts != nil || !f.tree {
+		t.Errorf("Expected ext to be skipped and tree applied, got ext=%q tree=%v", f.exts, f.tree)
+	}
+	if _, ok := origins["ext"]; ok {
+		t.Error("Skipped setting should not be reported as applied")
+	}
+}
+
+func TestFormatSettings(t *testing.T) {
+	f := newTestFlags(t, "-t")
+	layers := []configLayer{
+		{name: "project", path: "/p/.dump.yaml", settings: map[string]any{"ext": []any{"go", "*.md"}, "meta": []any{"hash", "size"}, "tree": false, "max-tokens": 10}},
+	}
+	origins, err := applyConfig(f.set, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := formatSettings(f.set, layers, origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# project: /p/.dump.yaml\n" +
+		"ext: [go, '*.md'] # project\n" +
+		"max-tokens: 10 # project\n" +
+		"meta: [hash, size] # project\n" +
+		"out-fmt: xml\n" +
+		"tree: true\n"
+	if got != expected {
+		t.Errorf("formatSettings = %q, expected %q", got, expected)
+	}
+}
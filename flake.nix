@@ -21,7 +21,7 @@
             pname = "dump";
             version = "0.6.0";
             src = ./.;
-            vendorHash = "sha256-1oNrXunA2Wdue+/2cUG2YTtDtKnUsrmcORSy6iBVSkM=";
+            vendorHash = "sha256-GQ8GNPBlS754+eK5x/vQwHL5seywVSTvmNkaAhgoX88=";
 
             buildPhase = ''
               runHook preBuild
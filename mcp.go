@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/kabilan108/dump/pkg/dump"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve dump's tools over the Model Context Protocol on stdio",
+	Long: `speak the Model Context Protocol (JSON-RPC 2.0 over stdin/stdout) so MCP clients can call dump as tools:
+dump_directory, list_files, tree, capture_tmux and fetch_url. tool arguments mirror the CLI flags, and
+defaults come from the config files and profile (-p) as for a normal run.`,
+	Example: `  claude mcp add dump -- dump mcp
+  dump -p review mcp                use the "review" profile as the defaults for every tool call`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := serverOptions()
+		if err != nil {
+			return err
+		}
+		return dump.ServeMCP(cmd.Context(), os.Stdin, os.Stdout, base)
+	},
+}
+
+// serverOptions are the defaults of the requests of `dump mcp` and `dump serve`:
+// the root flags with the config files and profile applied, minus the inputs and
+// outputs, which each request chooses for itself.
+func serverOptions() (dump.Options, error) {
+	flags := rootCmd.Flags()
+	layers, err := loadConfig(flags, profile)
+	if err != nil {
+		return dump.Options{}, err
+	}
+	if _, err := applyConfig(flags, layers); err != nil {
+		return dump.Options{}, err
+	}
+	base := opts
+	base.Dirs, base.URLs, base.TmuxSelectors, base.Commands = nil, nil, nil, nil
+	base.FilesFrom, base.Output, base.Copy, base.List, base.Tree = "", "", false, false, false
+	base.Snapshot, base.SinceSnapshot = "", false
+	base.SplitTokens, base.SplitBytes, base.OutDir = 0, 0, ""
+	base.URLTimeout = time.Duration(timeoutSec) * time.Second
+	base.CommandTimeout = time.Duration(cmdTimeoutSec) * time.Second
+	base.ExaAPIKey = os.Getenv("EXA_API_KEY")
+	base.Version = version
+	return base, nil
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestServerOptions(t *testing.T) {
+	user, project := withConfigDirs(t)
+	writeConfig(t, user, "output: dump.xml\nsnapshot: .dump-state.json\nsince-snapshot: true\n")
+	writeConfig(t, project, "max-tokens: 1000\n")
+	saved := opts
+	t.Cleanup(func() { opts = saved })
+
+	base, err := serverOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each request chooses its own outputs, so the server never writes files of its own
+	if base.Output != "" || base.Snapshot != "" || base.SinceSnapshot {
+		t.Errorf("serverOptions kept output=%q snapshot=%q since-snapshot=%v", base.Output, base.Snapshot, base.SinceSnapshot)
+	}
+	if base.MaxTokens != 1000 {
+		t.Errorf("MaxTokens = %d, expected 1000 from the project config", base.MaxTokens)
+	}
+}
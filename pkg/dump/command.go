@@ -0,0 +1,197 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CommandItem struct {
+	Command  string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+	TimedOut bool
+}
+
+// runShellCommand runs command through sh, capturing stdout and stderr separately.
+// A non-zero exit is not an error; err is only set if the command could not be run.
+func runShellCommand(ctx context.Context, command string, timeout time.Duration) (*CommandItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// a timeout kills the children of sh too, and background children that
+	// outlive sh can't keep the pipes open forever
+	killProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	item := &CommandItem{
+		Command:  command,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+		TimedOut: ctx.Err() == context.DeadlineExceeded,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		item.ExitCode = exitErr.ExitCode()
+	case item.TimedOut:
+		item.ExitCode = -1
+	default:
+		return nil, err
+	}
+	return item, nil
+}
+
+// CommandSource captures the output of shell commands.
+type CommandSource struct {
+	Commands []string
+	Timeout  time.Duration // per command
+}
+
+func (s *CommandSource) Name() string {
+	return "commands"
+}
+
+func (s *CommandSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	if env.List {
+		return nil, nil
+	}
+	results := make([]*CommandItem, len(s.Commands))
+	var wg sync.WaitGroup
+	fetchCommandsConcurrently(ctx, s.Commands, s.Timeout, env.Filter, env.Stderr, &wg, results)
+	wg.Wait()
+
+	var blocks []*Block
+	for _, item := range results {
+		if item != nil {
+			blocks = append(blocks, &Block{Command: item})
+		}
+	}
+	return blocks, nil
+}
+
+// fetchCommandsConcurrently runs shell commands via a worker pool. results[i] receives
+// the output of commands[i] and stays nil if the command could not be run.
+func fetchCommandsConcurrently(
+	ctx context.Context, commands []string, timeout time.Duration, filter *regexp.Regexp,
+	stderr io.Writer, wg *sync.WaitGroup, results []*CommandItem,
+) {
+	if len(commands) == 0 {
+		return
+	}
+
+	jobs := make(chan int, len(commands))
+	const maxConcurrency = 4
+	workerCount := len(commands)
+	if workerCount > maxConcurrency {
+		workerCount = maxConcurrency
+	}
+
+	// enqueue jobs
+	for i := range commands {
+		jobs <- i
+	}
+	close(jobs)
+
+	// start workers
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				command := commands[i]
+				item, err := runShellCommand(ctx, command, timeout)
+				if err != nil {
+					fmt.Fprintf(stderr, "error running command %q: %v\n", command, err)
+					continue
+				}
+				if item.TimedOut {
+					fmt.Fprintf(stderr, "command %q timed out after %s\n", command, timeout)
+				}
+				if filter != nil {
+					item.Stdout, err = filterContent(strings.NewReader(item.Stdout), filter)
+					if err == nil {
+						item.Stderr, err = filterContent(strings.NewReader(item.Stderr), filter)
+					}
+					if err != nil {
+						fmt.Fprintf(stderr, "error filtering output of %q: %v\n", command, err)
+						continue
+					}
+				}
+				results[i] = item
+			}
+		}()
+	}
+}
+
+func formatCommandItem(item CommandItem, format string) string {
+	duration := item.Duration.Round(time.Millisecond).String()
+	switch format {
+	case "md":
+		var sb strings.Builder
+		fence := mdFence(item.Command, item.Stdout, item.Stderr)
+		fmt.Fprintf(&sb, "%sshell\n$ %s\n# exit_code=%d duration=%s", fence, item.Command, item.ExitCode, duration)
+		if item.TimedOut {
+			sb.WriteString(" timed_out=true")
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString(item.Stdout)
+		if item.Stderr != "" {
+			sb.WriteString("\n# stderr\n")
+			sb.WriteString(item.Stderr)
+		}
+		if !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fence + "\n")
+		return sb.String()
+	case "xml-strict":
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "<command cmd='%s' exit_code='%d' duration='%s'", xmlAttr(item.Command), item.ExitCode, duration)
+		if item.TimedOut {
+			sb.WriteString(" timed_out='true'")
+		}
+		sb.WriteString(">\n")
+		if item.Stdout != "" {
+			fmt.Fprintf(&sb, "<stdout>%s</stdout>\n", cdata(item.Stdout))
+		}
+		if item.Stderr != "" {
+			fmt.Fprintf(&sb, "<stderr>%s</stderr>\n", cdata(item.Stderr))
+		}
+		sb.WriteString("</command>\n")
+		return sb.String()
+	default:
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "<command cmd='%s' exit_code='%d' duration='%s'", item.Command, item.ExitCode, duration)
+		if item.TimedOut {
+			sb.WriteString(" timed_out='true'")
+		}
+		sb.WriteString(">\n")
+		if item.Stdout != "" {
+			fmt.Fprintf(&sb, "<stdout>\n%s</stdout>\n", item.Stdout)
+		}
+		if item.Stderr != "" {
+			fmt.Fprintf(&sb, "<stderr>\n%s</stderr>\n", item.Stderr)
+		}
+		sb.WriteString("</command>\n")
+		return sb.String()
+	}
+}
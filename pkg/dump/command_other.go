@@ -0,0 +1,9 @@
+//go:build !unix
+
+package dump
+
+import "os/exec"
+
+// killProcessGroup is a no-op without unix process groups; cancelling cmd kills
+// sh only.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,115 @@
+package dump
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunShellCommand(t *testing.T) {
+	t.Run("Captures streams and exit code", func(t *testing.T) {
+		item, err := runShellCommand(context.Background(), "echo out; echo err >&2; exit 3", 10*time.Second)
+		if err != nil {
+			t.Fatalf("runShellCommand: %v", err)
+		}
+		if item.Stdout != "out\n" || item.Stderr != "err\n" {
+			t.Errorf("Unexpected streams: stdout=%q stderr=%q", item.Stdout, item.Stderr)
+		}
+		if item.ExitCode != 3 || item.TimedOut {
+			t.Errorf("Expected exit code 3 without timeout, got %d (timed out: %v)", item.ExitCode, item.TimedOut)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		item, err := runShellCommand(context.Background(), "exec sleep 5", 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("runShellCommand: %v", err)
+		}
+		if !item.TimedOut || item.ExitCode != -1 {
+			t.Errorf("Expected timeout with exit code -1, got %d (timed out: %v)", item.ExitCode, item.TimedOut)
+		}
+	})
+
+	t.Run("Timeout kills children", func(t *testing.T) {
+		// the background sleep holds the pipes open until it is killed
+		item, err := runShellCommand(context.Background(), "sleep 5 & wait", 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("runShellCommand: %v", err)
+		}
+		if !item.TimedOut || item.Duration >= time.Second {
+			t.Errorf("Expected the timeout to end the command at once, took %s (timed out: %v)", item.Duration, item.TimedOut)
+		}
+	})
+}
+
+func TestFetchCommandsConcurrently(t *testing.T) {
+	commands := []string{"echo one", "echo two; echo skip", "echo three"}
+	results := make([]*CommandItem, len(commands))
+	var wg sync.WaitGroup
+	fetchCommandsConcurrently(context.Background(), commands, 10*time.Second, regexp.MustCompile(`^skip`), io.Discard, &wg, results)
+	wg.Wait()
+
+	for i, item := range results {
+		if item == nil {
+			t.Fatalf("Expected a result for %q", commands[i])
+		}
+		if item.Command != commands[i] {
+			t.Errorf("results[%d] = %q, expected %q", i, item.Command, commands[i])
+		}
+	}
+	if results[1].Stdout != "two\n" {
+		t.Errorf("Expected filter to drop matching lines, got %q", results[1].Stdout)
+	}
+}
+
+func TestFormatCommandItem(t *testing.T) {
+	item := CommandItem{
+		Command:  "go test ./...",
+		Stdout:   "ok\n",
+		Stderr:   "warning\n",
+		ExitCode: 1,
+		Duration: 1234567 * time.Microsecond,
+	}
+
+	t.Run("XML format", func(t *testing.T) {
+		got := formatCommandItem(item, "xml")
+		expected := "<command cmd='go test ./...' exit_code='1' duration='1.235s'>\n" +
+			"<stdout>\nok\n</stdout>\n<stderr>\nwarning\n</stderr>\n</command>\n"
+		if got != expected {
+			t.Errorf("formatCommandItem(xml) = %q, expected %q", got, expected)
+		}
+	})
+
+	t.Run("Markdown format", func(t *testing.T) {
+		got := formatCommandItem(item, "md")
+		expected := "```shell\n$ go test ./...\n# exit_code=1 duration=1.235s\n\nok\n\n# stderr\nwarning\n```\n"
+		if got != expected {
+			t.Errorf("formatCommandItem(md) = %q, expected %q", got, expected)
+		}
+	})
+
+	t.Run("Markdown format without trailing newlines", func(t *testing.T) {
+		bare := item
+		bare.Stdout, bare.Stderr = "hi", "oops"
+		got := formatCommandItem(bare, "md")
+		expected := "```shell\n$ go test ./...\n# exit_code=1 duration=1.235s\n\nhi\n# stderr\noops\n```\n"
+		if got != expected {
+			t.Errorf("formatCommandItem(md) = %q, expected %q", got, expected)
+		}
+	})
+
+	t.Run("Strict XML escapes the command", func(t *testing.T) {
+		quoted := item
+		quoted.Command = "grep 'a' < in"
+		quoted.Stderr = ""
+		got := formatCommandItem(quoted, "xml-strict")
+		expected := "<command cmd='grep &apos;a&apos; &lt; in' exit_code='1' duration='1.235s'>\n" +
+			"<stdout><![CDATA[ok\n]]></stdout>\n</command>\n"
+		if got != expected {
+			t.Errorf("formatCommandItem(xml-strict) = %q, expected %q", got, expected)
+		}
+	})
+}
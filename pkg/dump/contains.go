@@ -0,0 +1,85 @@
+package dump
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// regionSeparator goes between the non-adjacent regions of a file dumped with
+// --context, as in grep.
+const regionSeparator = "--"
+
+// errNoMatch is returned for file content that --contains excludes.
+var errNoMatch = errors.New("no line matches --contains")
+
+// contentMatcher selects files by their content (--contains) and optionally cuts
+// them down to the matching regions (--context).
+type contentMatcher struct {
+	patterns []*regexp.Regexp
+	all      bool // every pattern must match somewhere in the file
+	context  int  // lines kept around each match; negative keeps whole files
+}
+
+func newContentMatcher(patterns []string, all bool, context int) (*contentMatcher, error) {
+	m := &contentMatcher{all: all, context: context}
+	for _, p := range patterns {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile --contains pattern %q: %w", p, err)
+		}
+		m.patterns = append(m.patterns, r)
+	}
+	return m, nil
+}
+
+// apply returns the lines to dump for a file, or errNoMatch when the file does
+// not match.
+func (m *contentMatcher) apply(lines []contentLine) ([]contentLine, error) {
+	hits := make([]bool, len(lines))
+	matched := make([]bool, len(m.patterns))
+	for i, l := range lines {
+		for j, r := range m.patterns {
+			if r.MatchString(l.text) {
+				hits[i], matched[j] = true, true
+			}
+		}
+	}
+	ok := slices.Contains(matched, true)
+	if m.all {
+		ok = !slices.Contains(matched, false)
+	}
+	if !ok {
+		return nil, errNoMatch
+	}
+	if m.context < 0 {
+		return lines, nil
+	}
+	return regions(lines, hits, m.context), nil
+}
+
+// regions keeps the hit lines and up to context lines around each, with a
+// separator between regions that are not adjacent.
+func regions(lines []contentLine, hits []bool, context int) []contentLine {
+	keep := make([]bool, len(lines))
+	for i, hit := range hits {
+		if !hit {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(lines)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+	var kept []contentLine
+	for i, l := range lines {
+		if !keep[i] {
+			continue
+		}
+		if len(kept) > 0 && !keep[i-1] {
+			kept = append(kept, contentLine{text: regionSeparator})
+		}
+		kept = append(kept, l)
+	}
+	return kept
+}
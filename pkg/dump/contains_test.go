@@ -0,0 +1,101 @@
+package dump
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestContentMatcher(t *testing.T) {
+	src := "a\nfoo\nb\nc\nd\ne\nf\ng\nbar foo\nh\n"
+
+	testCases := []struct {
+		name     string
+		patterns []string
+		all      bool
+		context  int
+		expected string // "" when the file is excluded
+	}{
+		{"Any pattern keeps the whole file", []string{"nope", "bar"}, false, -1, src},
+		{"No match", []string{"nope"}, false, -1, ""},
+		{"All patterns", []string{"foo", "bar"}, true, -1, src},
+		{"All patterns, one missing", []string{"foo", "nope"}, true, -1, ""},
+		{"Matching lines only", []string{"foo"}, false, 0, "2  foo\n   --\n9  bar foo\n"},
+		{
+			"Context with separators",
+			[]string{"foo"}, false, 1,
+			" 1  a\n 2  foo\n 3  b\n    --\n 8  g\n 9  bar foo\n10  h\n",
+		},
+		{"Overlapping context merges regions", []string{"^c$", "^e$"}, false, 1, "3  b\n4  c\n5  d\n6  e\n7  f\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := newContentMatcher(tc.patterns, tc.all, tc.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &Env{contains: m, lineNumbers: tc.context >= 0}
+			got, _, err := env.fileContent("m.txt", src)
+			if tc.expected == "" {
+				if err != errNoMatch {
+					t.Errorf("fileContent = %q, %v, expected errNoMatch", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("fileContent = %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDirectoryContains(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"fetch.go":     "package a\n\nfunc fetchURLContent() {}\n",
+		"caller.go":    "package a\n\nfunc f() { fetchURLContent() }\n",
+		"unrelated.go": "package a\n",
+	}
+	writeFiles(t, dir, files)
+
+	for _, list := range []bool{false, true} {
+		env, err := newEnv(&Options{List: list, Tree: true, Contains: []string{`fetchURLContent`}, Context: -1, Stderr: io.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := (&DirectorySource{Dir: dir}).Collect(context.Background(), env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, b := range blocks {
+			if b.Tree != nil {
+				for _, c := range b.Tree.Children {
+					got = append(got, "tree:"+c.Name)
+				}
+				continue
+			}
+			got = append(got, filepath.Base(b.Item.Path))
+			if list && b.Item.Content != "" {
+				t.Errorf("Expected no content in list mode, got %q", b.Item.Content)
+			}
+		}
+		expected := []string{"tree:caller.go", "tree:fetch.go", "caller.go", "fetch.go"}
+		if list {
+			expected = expected[2:]
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("list=%v: got %q, expected %q", list, got, expected)
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("list=%v: got %q, expected %q", list, got, expected)
+				break
+			}
+		}
+	}
+}
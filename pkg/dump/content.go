@@ -0,0 +1,125 @@
+package dump
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// contentLine is one line of a file's content and its line number in the file.
+// Lines that dump adds, such as markers, have number 0.
+type contentLine struct {
+	num  int
+	text string // without the line break
+}
+
+// contentTransformer rewrites a file's lines before the line filter runs, for
+// example to reduce Go source to an outline. path is the file on disk.
+// Transformers keep the number of every line they pass through.
+type contentTransformer func(path string, lines []contentLine) ([]contentLine, error)
+
+// contentTransform is a contentTransformer and the attribute that marks the
+// documents it changed.
+type contentTransform struct {
+	name  string
+	apply contentTransformer
+}
+
+// sameLines reports whether a and b hold the same text.
+func sameLines(a, b []contentLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].text != b[i].text {
+			return false
+		}
+	}
+	return true
+}
+
+// splitContent splits s into lines numbered from 1. A final line break does not
+// start another line.
+func splitContent(s string) []contentLine {
+	if s == "" {
+		return nil
+	}
+	texts := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	lines := make([]contentLine, len(texts))
+	for i, text := range texts {
+		lines[i] = contentLine{num: i + 1, text: text}
+	}
+	return lines
+}
+
+// joinContent joins lines, ending each with a line break except the last when
+// final is false. With numbered set, every line is prefixed with its right-aligned
+// line number; added lines get a blank prefix.
+func joinContent(lines []contentLine, final, numbered bool) string {
+	width := 0
+	if numbered {
+		for _, l := range lines {
+			width = max(width, len(strconv.Itoa(l.num)))
+		}
+	}
+	var sb strings.Builder
+	for i, l := range lines {
+		switch {
+		case !numbered:
+			sb.WriteString(l.text)
+		case l.num == 0:
+			sb.WriteString(strings.Repeat(" ", width+2) + l.text)
+		case l.text == "":
+			fmt.Fprintf(&sb, "%*d", width, l.num)
+		default:
+			fmt.Fprintf(&sb, "%*d  %s", width, l.num, l.text)
+		}
+		if final || i < len(lines)-1 {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// lineTexts joins the text of lines back into source for transformers that work
+// on the whole file.
+func lineTexts(lines []contentLine) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// filterLines drops the lines that match filter. Carriage returns are removed
+// first, so patterns anchored with $ match CRLF files too.
+func filterLines(lines []contentLine, filter *regexp.Regexp) []contentLine {
+	kept := lines[:0:0]
+	for _, l := range lines {
+		l.text = strings.TrimSuffix(l.text, "\r")
+		if !filter.MatchString(l.text) {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// squeezeLines collapses each run of blank lines into a single empty line.
+func squeezeLines(lines []contentLine) []contentLine {
+	kept := lines[:0:0]
+	prevBlank := false
+	for _, l := range lines {
+		blank := strings.TrimSpace(l.text) == ""
+		if blank {
+			if prevBlank {
+				continue
+			}
+			l.text = ""
+		}
+		kept = append(kept, l)
+		prevBlank = blank
+	}
+	return kept
+}
@@ -0,0 +1,110 @@
+package dump
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// transformString runs a contentTransformer on s as if it were a file's content.
+func transformString(t contentTransformer, path, s string) (string, error) {
+	lines, err := t(path, splitContent(s))
+	if err != nil {
+		return "", err
+	}
+	return joinContent(lines, strings.HasSuffix(s, "\n"), false), nil
+}
+
+func TestSqueezeLines(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"a\n\n\n\nb\n", "a\n\nb\n"},
+		{"a\n  \n\t\n\nb\n", "a\n\nb\n"},
+		{"\n\na\n", "\na\n"},
+		{"a\nb\n", "a\nb\n"},
+		{"a\n\n", "a\n\n"},
+	}
+	for _, tc := range testCases {
+		got := joinContent(squeezeLines(splitContent(tc.input)), true, false)
+		if got != tc.expected {
+			t.Errorf("squeezeLines(%q) = %q, expected %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestFileContentLineNumbers(t *testing.T) {
+	src := "package main\n\n// comment\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\") // DROP\n\t// DROP me\n}\n"
+
+	testCases := []struct {
+		name     string
+		env      *Env
+		path     string
+		expected string
+	}{
+		{
+			"Plain",
+			&Env{lineNumbers: true},
+			"main.go",
+			"1  package main\n2\n3  // comment\n4  import \"fmt\"\n5\n6  func main() {\n7  \tfmt.Println(\"hi\") // DROP\n8  \t// DROP me\n9  }\n",
+		},
+		{
+			"Filter leaves gaps",
+			&Env{lineNumbers: true, Filter: regexp.MustCompile(`DROP`)},
+			"main.go",
+			"1  package main\n2\n3  // comment\n4  import \"fmt\"\n5\n6  func main() {\n9  }\n",
+		},
+		{
+			"Stripped comments and squeezed blanks leave gaps",
+			&Env{lineNumbers: true, squeezeBlank: true, transforms: []contentTransform{{"stripped", stripCommentsContent}}},
+			"main.go",
+			"1  package main\n2\n4  import \"fmt\"\n5\n6  func main() {\n7  \tfmt.Println(\"hi\")\n9  }\n",
+		},
+		{
+			"Outline keeps the signature line",
+			&Env{lineNumbers: true, transforms: []contentTransform{{"outline", outlineContent}}},
+			"main.go",
+			"1  package main\n2\n3  // comment\n4  import \"fmt\"\n5\n6  func main() { ... }\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := tc.env.fileContent(tc.path, src)
+			if err != nil {
+				t.Fatalf("fileContent: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("fileContent = %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestJoinContentMarkers(t *testing.T) {
+	lines := []contentLine{{num: 9, text: "a"}, {text: "... marker ..."}, {num: 10, text: "b"}}
+	expected := " 9  a\n    ... marker ...\n10  b"
+	if got := joinContent(lines, false, true); got != expected {
+		t.Errorf("joinContent = %q, expected %q", got, expected)
+	}
+}
+
+func TestFormatNumberedItem(t *testing.T) {
+	item := Item{Path: "a.go", Content: "1  package a\n", LineNumbers: true}
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{"xml", "<document path='a.go' line_numbers='true'>\n1  package a\n</document>\n"},
+		{"xml-strict", "<document path='a.go' line_numbers='true'>\n<![CDATA[1  package a\n]]>\n</document>\n"},
+		{"md", "```a.go line_numbers\n1  package a\n```\n"},
+	}
+	for _, tc := range testCases {
+		if got := formatItem(item, tc.format, "document"); got != tc.expected {
+			t.Errorf("formatItem(%s) = %q, expected %q", tc.format, got, tc.expected)
+		}
+	}
+	if got := formatJSONRecord(&Block{Item: &item}); !strings.Contains(got, `"line_numbers":true`) {
+		t.Errorf("Expected line_numbers in the json record, got %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file is sampled to tell text from binary, as in git.
+const sniffLen = 8000
+
+// textEncoding is what a file's content was detected as.
+type textEncoding int
+
+const (
+	encBinary textEncoding = iota
+	encUTF8
+	encUTF8BOM
+	encUTF16LE
+	encUTF16BE
+	encLatin1
+)
+
+// binaryMagic are the leading bytes of common binary formats, checked before
+// anything else so that a format that happens to look like text is not dumped.
+var binaryMagic = []struct {
+	magic string
+	kind  string
+}{
+	{"\x89PNG\r\n\x1a\n", "PNG image"},
+	{"\xff\xd8\xff", "JPEG image"},
+	{"GIF87a", "GIF image"},
+	{"GIF89a", "GIF image"},
+	{"\x00\x00\x01\x00", "ICO image"},
+	{"%PDF-", "PDF document"},
+	{"PK\x03\x04", "zip archive"},
+	{"PK\x05\x06", "zip archive"},
+	{"\x1f\x8b", "gzip archive"},
+	{"\xfd7zXZ\x00", "xz archive"},
+	{"(\xb5/\xfd", "zstd archive"},
+	{"7z\xbc\xaf\x27\x1c", "7z archive"},
+	{"\x7fELF", "ELF executable"},
+	{"\xcf\xfa\xed\xfe", "Mach-O executable"},
+	{"\xce\xfa\xed\xfe", "Mach-O executable"},
+	{"\xca\xfe\xba\xbe", "Mach-O or Java class file"},
+	{"\x00asm", "WebAssembly module"},
+	{"SQLite format 3\x00", "SQLite database"},
+	{"OggS", "Ogg media"},
+	{"fLaC", "FLAC audio"},
+	{"wOFF", "WOFF font"},
+	{"wOF2", "WOFF2 font"},
+	{"\x00\x01\x00\x00\x00", "TrueType font"},
+}
+
+// binaryKind names the format of binary content by its magic number, or returns
+// "" when it has none.
+func binaryKind(sample []byte) string {
+	for _, m := range binaryMagic {
+		if bytes.HasPrefix(sample, []byte(m.magic)) {
+			return m.kind
+		}
+	}
+	return ""
+}
+
+// detectEncoding classifies sample, the start of a file. truncated is set when
+// the file continues past the sample, so a rune cut off at its end is not held
+// against it.
+func detectEncoding(sample []byte, truncated bool) textEncoding {
+	if binaryKind(sample) != "" {
+		return encBinary
+	}
+	switch {
+	case bytes.HasPrefix(sample, []byte("\xef\xbb\xbf")):
+		return encUTF8BOM
+	case bytes.HasPrefix(sample, []byte("\xff\xfe")):
+		return encUTF16LE
+	case bytes.HasPrefix(sample, []byte("\xfe\xff")):
+		return encUTF16BE
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return encBinary
+	}
+	if truncated {
+		sample = trimPartialRune(sample)
+	}
+	if utf8.Valid(sample) {
+		return encUTF8
+	}
+	if looksLikeLatin1(sample) {
+		return encLatin1
+	}
+	return encBinary
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// looksLikeLatin1 reports whether b, which is not UTF-8, reads as Latin-1 (or
+// Windows-1252) text: almost no control characters or unassigned bytes.
+func looksLikeLatin1(b []byte) bool {
+	suspicious := 0
+	for _, c := range b {
+		switch {
+		case c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v' || c == 0x1b:
+		case c < 0x20 || c == 0x7f:
+			suspicious++
+		case c >= 0x80 && c < 0xa0 && cp1252[c-0x80] == 0:
+			suspicious++
+		}
+	}
+	return suspicious*100 <= len(b)
+}
+
+// cp1252 maps bytes 0x80-0x9f to runes. Files labelled Latin-1 are usually
+// Windows-1252, which puts curly quotes and dashes where Latin-1 has C1
+// controls; 0 marks the five bytes it leaves unassigned.
+var cp1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// decodeText converts data in encoding enc to UTF-8, dropping any byte order mark.
+func decodeText(data []byte, enc textEncoding) string {
+	switch enc {
+	case encUTF8BOM:
+		return string(data[3:])
+	case encUTF16LE, encUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == encUTF16BE {
+			order = binary.BigEndian
+		}
+		data = data[2:]
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units))
+	case encLatin1:
+		var sb strings.Builder
+		sb.Grow(len(data))
+		for _, c := range data {
+			if c >= 0x80 && c < 0xa0 && cp1252[c-0x80] != 0 {
+				sb.WriteRune(cp1252[c-0x80])
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String()
+	default:
+		return string(data)
+	}
+}
+
+// sniffFile detects the encoding of the file at path from its first sniffLen
+// bytes, which it also returns.
+func sniffFile(path string) (textEncoding, []byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return encBinary, nil, err
+	}
+	defer file.Close()
+
+	// read one byte more than the sample to learn whether the file continues
+	buf := make([]byte, sniffLen+1)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return encBinary, nil, err
+	}
+	sample := buf[:min(n, sniffLen)]
+	return detectEncoding(sample, n > sniffLen), sample, nil
+}
+
+// fileEncoding detects the encoding of data, a whole file, the same way
+// sniffFile does.
+func fileEncoding(data []byte) textEncoding {
+	return detectEncoding(data[:min(len(data), sniffLen)], len(data) > sniffLen)
+}
+
+// binaryModes are the ways --include-binary represents binary files.
+var binaryModes = map[string]struct{}{"skip": {}, "hex": {}, "base64": {}, "placeholder": {}}
+
+// formatBinary represents binary data as a hex dump, base64 in 76-column lines or
+// a one-line placeholder naming its format and size.
+func formatBinary(data []byte, mode string) string {
+	switch mode {
+	case "hex":
+		return hex.Dump(data)
+	case "base64":
+		s := base64.StdEncoding.EncodeToString(data)
+		var sb strings.Builder
+		for len(s) > 76 {
+			sb.WriteString(s[:76] + "\n")
+			s = s[76:]
+		}
+		if s != "" {
+			sb.WriteString(s + "\n")
+		}
+		return sb.String()
+	default:
+		kind := binaryKind(data)
+		if kind == "" {
+			kind = "binary data"
+		}
+		return fmt.Sprintf("[%s, %s]\n", kind, formatSize(int64(len(data))))
+	}
+}
@@ -0,0 +1,113 @@
+package dump
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sample    string
+		truncated bool
+		expected  textEncoding
+	}{
+		{"UTF-8", "héllo\n", false, encUTF8},
+		{"UTF-8 BOM", "\xef\xbb\xbfhi\n", false, encUTF8BOM},
+		{"UTF-16LE BOM", "\xff\xfeh\x00i\x00", false, encUTF16LE},
+		{"UTF-16BE BOM", "\xfe\xff\x00h\x00i", false, encUTF16BE},
+		{"Latin-1", "caf\xe9 na\xefve\n", false, encLatin1},
+		{"Windows-1252 quotes", "\x93quoted\x94\n", false, encLatin1},
+		{"NUL byte", "text\x00more", false, encBinary},
+		{"Control bytes", "\x01\x02\x03\x04\xe9", false, encBinary},
+		{"PNG magic", "\x89PNG\r\n\x1a\nrest", false, encBinary},
+		{"PDF magic", "%PDF-1.7\n", false, encBinary},
+		{"Rune cut off by the sample", "ab\xe2\x82", true, encUTF8},
+		{"Rune cut off at the end of the file", "ab\xe2\x82", false, encLatin1},
+		{"Empty", "", false, encUTF8},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectEncoding([]byte(tc.sample), tc.truncated); got != tc.expected {
+				t.Errorf("detectEncoding(%q) = %d, expected %d", tc.sample, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	testCases := []struct {
+		data     string
+		enc      textEncoding
+		expected string
+	}{
+		{"\xef\xbb\xbfhi\n", encUTF8BOM, "hi\n"},
+		{"\xff\xfeh\x00\xe9\x00\n\x00", encUTF16LE, "hé\n"},
+		{"\xfe\xff\x00h\xd8\x3d\xde\x00", encUTF16BE, "h😀"},
+		{"caf\xe9 \x93q\x94 \x80", encLatin1, "café “q” €"},
+	}
+	for _, tc := range testCases {
+		if got := decodeText([]byte(tc.data), tc.enc); got != tc.expected {
+			t.Errorf("decodeText(%q) = %q, expected %q", tc.data, got, tc.expected)
+		}
+	}
+}
+
+func TestSniffFileLongUTF8(t *testing.T) {
+	// a multi-byte rune straddles the end of the sample
+	content := strings.Repeat("a", sniffLen-1) + "é and more"
+	path := filepath.Join(t.TempDir(), "long.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	enc, _, err := sniffFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc != encUTF8 {
+		t.Errorf("sniffFile = %d, expected UTF-8", enc)
+	}
+}
+
+func TestDumpFileBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logo.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\nabc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{"placeholder", "[PNG image, 11 B]\n"},
+		{"base64", "iVBORw0KGgphYmM=\n"},
+		{"hex", "00000000  89 50 4e 47 0d 0a 1a 0a  61 62 63                 |.PNG....abc|\n"},
+	}
+	for _, tc := range testCases {
+		item, err := dumpFile(path, "logo.png", &Env{binary: tc.mode})
+		if err != nil {
+			t.Fatalf("dumpFile(%s): %v", tc.mode, err)
+		}
+		if item.Content != tc.expected || item.Binary != tc.mode {
+			t.Errorf("dumpFile(%s) = %q (binary %q), expected %q", tc.mode, item.Content, item.Binary, tc.expected)
+		}
+	}
+
+	item := Item{Path: "logo.png", Content: "iVBORw0KGgphYmM=\n", Binary: "base64"}
+	if got := formatItem(item, "xml", "document"); got != "<document path='logo.png' binary='base64'>\niVBORw0KGgphYmM=\n</document>\n" {
+		t.Errorf("formatItem(xml) = %q", got)
+	}
+	if got := formatItem(item, "md", "document"); !strings.HasPrefix(got, "```logo.png binary=base64\n") {
+		t.Errorf("formatItem(md) = %q", got)
+	}
+}
+
+func TestFormatBinaryBase64Lines(t *testing.T) {
+	got := formatBinary(make([]byte, 100), "base64")
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 2 || len(lines[0]) != 76 {
+		t.Errorf("Expected 76-column lines, got %q", got)
+	}
+}
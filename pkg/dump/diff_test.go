@@ -0,0 +1,62 @@
+package dump
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	numbered := func(from, to int) string {
+		var sb strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&sb, "%d\n", i)
+		}
+		return sb.String()
+	}
+
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"Equal", "a\n", "a\n", ""},
+		{"Change", "one\ntwo\nthree\n", "one\n2\nthree\n",
+			"--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"},
+		{"New file", "", "a\nb\n",
+			"--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"Deleted content", "a\n", "",
+			"--- a/f\n+++ b/f\n@@ -1 +0,0 @@\n-a\n"},
+		{"Missing final newline", "a\nb\n", "a\nb",
+			"--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n"},
+		{"Separate hunks", numbered(1, 20), strings.Replace(strings.Replace(numbered(1, 20), "2\n", "two\n", 1), "19\n", "nineteen\n", 1),
+			"--- a/f\n+++ b/f\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+nineteen\n 20\n"},
+		{"Merged hunks", numbered(1, 10), strings.Replace(strings.Replace(numbered(1, 10), "2\n", "two\n", 1), "8\n", "eight\n", 1),
+			"--- a/f\n+++ b/f\n" +
+				"@@ -1,10 +1,10 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unifiedDiff("a/f", "b/f", tc.a, tc.b); got != tc.expected {
+				t.Errorf("unifiedDiff = %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	a := []string{"a", "b", "c", "d", "e"}
+	b := []string{"a", "x", "c", "e", "y"}
+	var changes int
+	for _, op := range diffLines(a, b) {
+		if op.kind != ' ' {
+			changes++
+		}
+	}
+	// b -> x, drop d, add y
+	if changes != 4 {
+		t.Errorf("Expected 4 changed lines, got %d: %v", changes, diffLines(a, b))
+	}
+}
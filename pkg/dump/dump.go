@@ -0,0 +1,914 @@
+// Package dump collects files, URLs, tmux panes and command output into a single
+// document that is easy to paste into an LLM context window.
+//
+// A dump is driven by Options: Run turns them into a list of Sources, collects
+// their Blocks concurrently, applies the token budget and writes the result with
+// a Renderer. Custom sources can be added through Options.Sources.
+package dump
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// Options configures a dump. Fields mirror the dump CLI flags.
+type Options struct {
+	// Dirs are directories to walk.
+	Dirs []string
+	// URLs are fetched via the Exa API, which requires ExaAPIKey.
+	URLs       []string
+	ExaAPIKey  string
+	LiveCrawl  bool
+	URLTimeout time.Duration
+	// TmuxSelectors are tmux panes to capture (current, all, %id, win.pane, ...).
+	TmuxSelectors []string
+	TmuxLines     int
+	// Commands are shell commands whose output is captured.
+	Commands       []string
+	CommandTimeout time.Duration
+	// ReadStdin wraps everything read from Stdin as one item labelled StdinLabel.
+	ReadStdin  bool
+	StdinLabel string
+	// FilesFrom names a newline or NUL separated list of files to dump; "-" reads it from Stdin.
+	FilesFrom string
+	Stdin     io.Reader
+
+	// Globs and Exts select files by name (OR semantics); Ignore excludes them.
+	Globs  []string
+	Exts   []string
+	Ignore []string
+	// Filter is a regex; matching lines are dropped from all captured content.
+	Filter string
+	// Contains selects files whose content has a line matching any of these
+	// regexes, or all of them with ContainsAll. A Context of 0 or more cuts
+	// selected files down to the matching lines and that many lines around each,
+	// numbered as with LineNumbers; a negative Context keeps whole files.
+	Contains    []string
+	ContainsAll bool
+	Context     int
+
+	// ChangedSince, Staged and Unstaged restrict directories to files git reports
+	// as changed; Diff adds each file's unified diff (also with SinceSnapshot).
+	ChangedSince string
+	Staged       bool
+	Unstaged     bool
+	Diff         bool
+
+	// Format is xml, xml-strict, md, json or jsonl; XMLTag wraps file items in xml.
+	Format string
+	XMLTag string
+	// Renderer overrides Format when set.
+	Renderer Renderer
+	// List writes file paths only; Tree adds a tree block per directory.
+	List bool
+	Tree bool
+
+	// Outline reduces source files in supported languages (Go) to their API
+	// surface: declarations, signatures and doc comments, with bodies elided.
+	Outline bool
+	// StripComments removes comments from files in languages it recognizes by
+	// extension; SqueezeBlank collapses runs of blank lines in files into one.
+	StripComments bool
+	SqueezeBlank  bool
+	// LineNumbers prefixes each line of a file with its line number in the file,
+	// so lines dropped by Filter or StripComments leave gaps in the numbering.
+	LineNumbers bool
+
+	// IncludeGenerated dumps the files that are skipped by default: lock files,
+	// generated files (by name, a "Code generated ... DO NOT EDIT." header or the
+	// linguist-generated attribute), minified code and vendored code (vendor/,
+	// node_modules/, ... or the linguist-vendored attribute).
+	IncludeGenerated bool
+	// Roots, when set, confines the dump to these directories: a directory outside
+	// them fails, and a file that resolves outside them (through a symlink) is
+	// skipped. Tmux, command and URL inputs are not affected.
+	Roots []string
+
+	// MaxFileSize skips files larger than this many bytes (0 = unlimited). MaxLines
+	// cuts files longer than that many lines; Head and Tail keep the first and last
+	// lines of each file cut (of every file when MaxLines is 0). Cut lines are
+	// replaced with a "... [truncated N lines] ..." marker.
+	MaxFileSize int64
+	MaxLines    int
+	Head        int
+	Tail        int
+	// IncludeBinary is how binary files are dumped: skip (the default when
+	// empty), hex (a hex dump), base64 or placeholder (a line naming the format
+	// and size). Text in UTF-16 or Latin-1 is converted to UTF-8 either way.
+	IncludeBinary string
+	// Meta adds attributes to each file: hash (sha256), size (bytes), lines, lang,
+	// mtime and blob (the git blob id, for files in a git work tree). They describe
+	// the file on disk, not the dumped content.
+	Meta []string
+
+	// MaxTokens is a budget for the whole dump (0 = unlimited), counted with Tokenizer.
+	MaxTokens int
+	Tokenizer string
+
+	// SplitTokens or SplitBytes split the dump into numbered part files of at most
+	// that size in OutDir, with a manifest.json listing what landed where. Nothing
+	// is written to the Run writer.
+	SplitTokens int
+	SplitBytes  int
+	OutDir      string
+
+	// Snapshot names a file that records the hash of each file the dump
+	// carried, written after the dump. With SinceSnapshot only files that are new
+	// or changed since that record are dumped (with Diff, each changed file's diff
+	// against it), followed by a list of the recorded files that are gone.
+	Snapshot      string
+	SinceSnapshot bool
+
+	// Output writes the dump to a file (atomically, via a temporary file and rename)
+	// and Copy puts it on the system clipboard, instead of writing it to the Run
+	// writer. Both report the size of what they wrote on Stderr.
+	Output string
+	Copy   bool
+
+	// Order lists input kinds ("dir", "files-from", "stdin", "tmux", "cmd" or "url")
+	// in command-line order: the n-th "dir" is Dirs[n], and so on. Inputs it does not
+	// mention follow in that default order.
+	Order []string
+	// Sort orders the files of each directory and file list: path, size, mtime,
+	// extension or git-recency. Empty keeps walk order (by path) for directories
+	// and list order for FilesFrom.
+	Sort string
+
+	// Redact is off, mask (replace secrets with placeholders, the default when empty)
+	// or fail (refuse to write a dump containing secrets). RedactPatterns are extra
+	// regexes named custom, and RedactRules extra name=REGEX rules.
+	Redact         string
+	RedactPatterns []string
+	RedactRules    []string
+
+	// Sources are custom sources, collected after the built-in ones.
+	Sources []Source
+	// Version is reported in json metadata.
+	Version string
+	// Stderr receives diagnostics and the token budget report.
+	Stderr io.Writer
+}
+
+// DefaultOptions returns the options used by the dump CLI when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		URLTimeout:     15 * time.Second,
+		TmuxLines:      500,
+		CommandTimeout: 60 * time.Second,
+		StdinLabel:     "stdin",
+		Context:        -1,
+		Stdin:          os.Stdin,
+		Format:         "xml",
+		XMLTag:         "document",
+		Tokenizer:      "cl100k",
+		Redact:         "mask",
+		Version:        "dev",
+		Stderr:         os.Stderr,
+	}
+}
+
+// Env carries the compiled options shared by every source during a run.
+type Env struct {
+	// Filter drops matching lines from captured content; nil keeps everything.
+	Filter *regexp.Regexp
+	// List asks sources for file paths only; content need not be read.
+	List bool
+	// Tree asks sources to add a tree block for what they walked.
+	Tree bool
+	// Stderr receives diagnostics for items that could not be captured. Sources
+	// run concurrently, so writes to it are serialized.
+	Stderr io.Writer
+
+	opts         *Options
+	globs        []glob.Glob
+	extSet       map[string]struct{}
+	transforms   []contentTransform // applied to file content, in order
+	squeezeBlank bool
+	contains     *contentMatcher // nil dumps every file
+	lineNumbers  bool
+	limits       lineLimits
+	binary       string          // --include-binary mode
+	skipped      skipList        // files left out, reported after collection
+	roots        []string        // resolved Roots; nil allows every path
+	meta         map[string]bool // --meta attributes to add
+	repos        repoCache       // for the blob attribute
+	snapshotFile string          // absolute --snapshot path
+	// absolute paths of the files and directory the dump writes, left out of
+	// walks so a run never dumps the output of the previous one
+	written map[string]bool
+	outDir  string
+}
+
+// fileContent runs a file's content through the content transforms (--outline,
+// --strip-comments), the line filter, --squeeze-blank, --contains and the line
+// limits, keeping each line's original number for --line-numbers. It also
+// returns the names of the steps that changed the content, for Item.Transforms,
+// and errNoMatch for a file that --contains excludes.
+func (e *Env) fileContent(path, content string) (string, []string, error) {
+	lines := splitContent(content)
+	var applied []string
+	mark := func(name string, before []contentLine, changed bool) {
+		if changed || !sameLines(before, lines) {
+			applied = appendTransform(applied, name)
+		}
+	}
+	var err error
+	for _, t := range e.transforms {
+		before := lines
+		if lines, err = t.apply(path, lines); err != nil {
+			return "", nil, err
+		}
+		mark(t.name, before, false)
+	}
+	// filtered content always ends with a line break
+	final := strings.HasSuffix(content, "\n") || e.Filter != nil
+	if e.Filter != nil {
+		before := lines
+		lines = filterLines(lines, e.Filter)
+		mark("filtered", before, !strings.HasSuffix(content, "\n") && len(lines) > 0)
+	}
+	if e.squeezeBlank {
+		before := lines
+		lines = squeezeLines(lines)
+		mark("squeezed", before, false)
+	}
+	if e.contains != nil {
+		before := lines
+		if lines, err = e.contains.apply(lines); err != nil {
+			return "", nil, err
+		}
+		mark("filtered", before, false)
+	}
+	before := lines
+	lines = e.limits.apply(lines)
+	mark("truncated", before, false)
+	return joinContent(lines, final, e.lineNumbers), applied, nil
+}
+
+// FilterContent drops the lines of s that match Filter.
+func (e *Env) FilterContent(s string) (string, error) {
+	if e.Filter == nil {
+		return s, nil
+	}
+	return filterContent(strings.NewReader(s), e.Filter)
+}
+
+// syncWriter serializes writes to w, which may be a plain buffer that sources
+// running at the same time would otherwise race on.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func newEnv(opts *Options) (*Env, error) {
+	env := &Env{
+		List:   opts.List,
+		Tree:   opts.Tree && !opts.List,
+		Stderr: opts.Stderr,
+		opts:   opts,
+	}
+	if env.Stderr == nil {
+		env.Stderr = io.Discard
+	}
+	env.Stderr = &syncWriter{w: env.Stderr}
+
+	if opts.Filter != "" {
+		r, err := regexp.Compile(opts.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex filter: %w", err)
+		}
+		env.Filter = r
+	}
+
+	globs, err := compilePatterns(opts.Globs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile glob patterns: %w", err)
+	}
+	env.globs = globs
+
+	if opts.Outline {
+		env.transforms = append(env.transforms, contentTransform{"outline", outlineContent})
+	}
+	if opts.StripComments {
+		env.transforms = append(env.transforms, contentTransform{"stripped", stripCommentsContent})
+	}
+	env.squeezeBlank = opts.SqueezeBlank
+	if len(opts.Contains) > 0 {
+		env.contains, err = newContentMatcher(opts.Contains, opts.ContainsAll, opts.Context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// regions are only readable with their line numbers
+	env.lineNumbers = opts.LineNumbers || env.contains != nil && opts.Context >= 0
+	env.binary = opts.IncludeBinary
+	if len(opts.Meta) > 0 {
+		env.meta = make(map[string]bool)
+		for _, m := range opts.Meta {
+			env.meta[m] = true
+		}
+	}
+	env.written = make(map[string]bool)
+	if opts.Snapshot != "" {
+		if env.snapshotFile, err = filepath.Abs(opts.Snapshot); err != nil {
+			return nil, fmt.Errorf("failed to resolve snapshot %q: %w", opts.Snapshot, err)
+		}
+		env.written[env.snapshotFile] = true
+	}
+	if opts.Output != "" {
+		output, err := filepath.Abs(opts.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve output %q: %w", opts.Output, err)
+		}
+		env.written[output] = true
+	}
+	if opts.OutDir != "" {
+		if env.outDir, err = filepath.Abs(opts.OutDir); err != nil {
+			return nil, fmt.Errorf("failed to resolve --out-dir %q: %w", opts.OutDir, err)
+		}
+	}
+	env.limits = lineLimits{maxLines: opts.MaxLines, head: opts.Head, tail: opts.Tail}
+	if len(opts.Roots) > 0 {
+		if env.roots, err = resolveRoots(opts.Roots); err != nil {
+			return nil, err
+		}
+	}
+
+	// normalize extension filters into a set for quick lookup
+	env.extSet = make(map[string]struct{})
+	for _, e := range opts.Exts {
+		norm := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), ".")
+		if norm == "" {
+			// allow matching files without an extension if user passes empty or "."
+			env.extSet[""] = struct{}{}
+			continue
+		}
+		env.extSet[norm] = struct{}{}
+	}
+	return env, nil
+}
+
+type Item struct {
+	Path    string
+	Content string
+	Diff    string // unified diff shown next to the content (--diff)
+	// LineNumbers is set when each line of Content starts with its line number
+	LineNumbers bool
+	// Binary is set to hex, base64 or placeholder when Content represents a
+	// binary file (--include-binary)
+	Binary string
+	// Meta holds the attributes asked for with --meta; nil for none
+	Meta *FileMeta
+	// Transforms names the steps that changed Content from the file on disk:
+	// outline, stripped, filtered, squeezed, truncated or redacted
+	Transforms []string
+
+	file  string         // path on disk, for --sort
+	state *snapshotEntry // the file as --snapshot records it
+}
+
+// appendTransform adds name to an Item's Transforms unless it is already there.
+func appendTransform(transforms []string, name string) []string {
+	if slices.Contains(transforms, name) {
+		return transforms
+	}
+	return append(slices.Clip(transforms), name)
+}
+
+// Block is a single unit of dump output: a directory tree, a file or URL item,
+// a tmux pane, a shell command, or the files removed since a snapshot. Exactly
+// one field is set.
+type Block struct {
+	Tree    *TreeNode
+	Item    *Item
+	Tmux    *TmuxPaneItem
+	Command *CommandItem
+	Removed *RemovedList
+}
+
+// Kind returns tree, tmux, command, removed, url or file.
+func (b *Block) Kind() string {
+	switch {
+	case b.Tree != nil:
+		return "tree"
+	case b.Removed != nil:
+		return "removed"
+	case b.Tmux != nil:
+		return "tmux"
+	case b.Command != nil:
+		return "command"
+	case isURL(b.Item.Path):
+		return "url"
+	default:
+		return "file"
+	}
+}
+
+// Label identifies the block: a path, URL, pane id or command line.
+func (b *Block) Label() string {
+	switch {
+	case b.Tree != nil:
+		return b.Tree.Path
+	case b.Tmux != nil:
+		return b.Tmux.ID
+	case b.Command != nil:
+		return b.Command.Command
+	case b.Removed != nil:
+		return "removed"
+	default:
+		return b.Item.Path
+	}
+}
+
+// body returns a pointer to the block's truncatable content (stdout for commands),
+// or nil for trees and removed lists.
+func (b *Block) body() *string {
+	switch {
+	case b.Tree != nil, b.Removed != nil:
+		return nil
+	case b.Tmux != nil:
+		return &b.Tmux.Content
+	case b.Command != nil:
+		return &b.Command.Stdout
+	default:
+		return &b.Item.Content
+	}
+}
+
+// Source produces the blocks for one input of a dump.
+type Source interface {
+	// Name describes the source in diagnostics.
+	Name() string
+	// Collect gathers the source's blocks. Failures that only affect some items
+	// are reported on env.Stderr; an error means the source produced nothing.
+	Collect(ctx context.Context, env *Env) ([]*Block, error)
+}
+
+// inputKinds are the kinds of built-in inputs, in their default order.
+var inputKinds = []string{"dir", "files-from", "stdin", "tmux", "cmd", "url"}
+
+// input is one built-in input: a directory, file list, tmux selector, command or URL.
+type input struct {
+	kind  string
+	value string
+}
+
+// inputs returns the built-in inputs of opts in output order.
+func (opts *Options) inputs() []input {
+	values := map[string][]string{
+		"dir":  opts.Dirs,
+		"tmux": opts.TmuxSelectors,
+		"cmd":  opts.Commands,
+		"url":  opts.URLs,
+	}
+	if opts.FilesFrom != "" {
+		values["files-from"] = []string{opts.FilesFrom}
+	}
+	if opts.ReadStdin {
+		values["stdin"] = []string{opts.StdinLabel}
+	}
+	builtin := len(opts.Dirs) + len(opts.URLs) + len(opts.TmuxSelectors) + len(opts.Commands)
+	if builtin == 0 && !opts.ReadStdin && opts.FilesFrom == "" && len(opts.Sources) == 0 {
+		values["dir"] = []string{"."}
+	}
+
+	used := make(map[string]int)
+	var inputs []input
+	take := func(kind string) {
+		if n := used[kind]; n < len(values[kind]) {
+			inputs = append(inputs, input{kind: kind, value: values[kind][n]})
+			used[kind]++
+		}
+	}
+	for _, kind := range opts.Order {
+		take(kind)
+	}
+	for _, kind := range inputKinds {
+		for used[kind] < len(values[kind]) {
+			take(kind)
+		}
+	}
+	return inputs
+}
+
+// sources builds the built-in sources for opts, in input order, followed by
+// opts.Sources.
+func (opts *Options) sources() ([]Source, error) {
+	// resolve git changes up front so a non-repository fails the whole run
+	changeSets, err := gitChangeSets(opts.ChangedSince, opts.Staged, opts.Unstaged)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Diff && len(changeSets) == 0 && !opts.SinceSnapshot {
+		return nil, fmt.Errorf("--diff requires --changed-since, --staged, --unstaged or --since-snapshot")
+	}
+
+	var sources []Source
+	inputs := opts.inputs()
+	for i := 0; i < len(inputs); i++ {
+		in := inputs[i]
+		switch in.kind {
+		case "dir":
+			src := &DirectorySource{Dir: in.value}
+			if len(changeSets) > 0 {
+				absDir, err := filepath.Abs(in.value)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve directory %q: %w", in.value, err)
+				}
+				src.changes, err = loadGitChanges(absDir, changeSets, opts.Diff)
+				if err != nil {
+					return nil, err
+				}
+			}
+			sources = append(sources, src)
+			continue
+		case "files-from":
+			sources = append(sources, &FileListSource{List: in.value, Stdin: opts.Stdin})
+			continue
+		case "stdin":
+			sources = append(sources, &StdinSource{Reader: opts.Stdin, Label: in.value})
+			continue
+		}
+
+		// consecutive tmux selectors, commands or URLs share one worker pool
+		values := []string{in.value}
+		for i+1 < len(inputs) && inputs[i+1].kind == in.kind {
+			i++
+			values = append(values, inputs[i].value)
+		}
+		switch in.kind {
+		case "tmux":
+			sources = append(sources, &TmuxSource{Selectors: values, Lines: opts.TmuxLines})
+		case "cmd":
+			sources = append(sources, &CommandSource{Commands: values, Timeout: opts.CommandTimeout})
+		case "url":
+			sources = append(sources, &URLSource{
+				URLs:      values,
+				APIKey:    opts.ExaAPIKey,
+				LiveCrawl: opts.LiveCrawl,
+				Timeout:   opts.URLTimeout,
+			})
+		}
+	}
+	return append(sources, opts.Sources...), nil
+}
+
+func (opts *Options) validate() error {
+	if opts.TmuxLines < 0 {
+		return fmt.Errorf("invalid --tmux-lines %d (must be >= 0)", opts.TmuxLines)
+	}
+	if len(opts.Commands) > 0 && opts.CommandTimeout <= 0 {
+		return fmt.Errorf("invalid --cmd-timeout %s (must be > 0)", opts.CommandTimeout)
+	}
+	if opts.Context > 0 && len(opts.Contains) == 0 {
+		return fmt.Errorf("--context requires --contains")
+	}
+	if opts.MaxFileSize < 0 {
+		return fmt.Errorf("invalid --max-file-size %d (must be >= 0)", opts.MaxFileSize)
+	}
+	if opts.MaxLines < 0 {
+		return fmt.Errorf("invalid --max-lines %d (must be >= 0)", opts.MaxLines)
+	}
+	if opts.Head < 0 {
+		return fmt.Errorf("invalid --head %d (must be >= 0)", opts.Head)
+	}
+	if opts.Tail < 0 {
+		return fmt.Errorf("invalid --tail %d (must be >= 0)", opts.Tail)
+	}
+	if opts.MaxLines > 0 && opts.Head+opts.Tail > opts.MaxLines {
+		return fmt.Errorf("--head plus --tail (%d) must not exceed --max-lines %d", opts.Head+opts.Tail, opts.MaxLines)
+	}
+	if _, ok := binaryModes[opts.IncludeBinary]; opts.IncludeBinary != "" && !ok {
+		return fmt.Errorf("invalid --include-binary %q (must be skip, hex, base64 or placeholder)", opts.IncludeBinary)
+	}
+	for _, m := range opts.Meta {
+		if !slices.Contains(metaFields, m) {
+			return fmt.Errorf("invalid --meta %q (must be %s)", m, strings.Join(metaFields, ", "))
+		}
+	}
+	if opts.MaxTokens < 0 {
+		return fmt.Errorf("invalid --max-tokens %d (must be >= 0)", opts.MaxTokens)
+	}
+	if opts.SplitTokens < 0 {
+		return fmt.Errorf("invalid --split-tokens %d (must be >= 0)", opts.SplitTokens)
+	}
+	if opts.SplitBytes < 0 {
+		return fmt.Errorf("invalid --split-bytes %d (must be >= 0)", opts.SplitBytes)
+	}
+	if opts.SplitTokens > 0 && opts.SplitBytes > 0 {
+		return fmt.Errorf("cannot use both --split-tokens and --split-bytes")
+	}
+	split := opts.SplitTokens > 0 || opts.SplitBytes > 0
+	if split && opts.OutDir == "" {
+		return fmt.Errorf("--split-tokens and --split-bytes require --out-dir")
+	}
+	if !split && opts.OutDir != "" {
+		return fmt.Errorf("--out-dir requires --split-tokens or --split-bytes")
+	}
+	if split && (opts.Output != "" || opts.Copy) {
+		return fmt.Errorf("--output and --copy cannot be used with a split dump (parts go to --out-dir)")
+	}
+	if opts.SinceSnapshot && opts.Snapshot == "" {
+		return fmt.Errorf("--since-snapshot requires --snapshot")
+	}
+	if opts.Snapshot != "" && opts.List {
+		return fmt.Errorf("--snapshot cannot be used with --list")
+	}
+	if _, ok := sortModes[opts.Sort]; opts.Sort != "" && !ok {
+		return fmt.Errorf("invalid --sort %q (must be path, size, mtime, extension or git-recency)", opts.Sort)
+	}
+	for _, kind := range opts.Order {
+		if !slices.Contains(inputKinds, kind) {
+			return fmt.Errorf("invalid input kind %q in Order", kind)
+		}
+	}
+	switch opts.Redact {
+	case "", "off", "mask", "fail":
+	default:
+		return fmt.Errorf("invalid --redact %q (must be off, mask or fail)", opts.Redact)
+	}
+	if opts.ReadStdin && opts.FilesFrom == "-" {
+		return fmt.Errorf("cannot read both content (-) and --files-from from stdin")
+	}
+	if len(opts.URLs) > 0 && !opts.List && opts.ExaAPIKey == "" {
+		return fmt.Errorf("EXA_API_KEY environment variable is required for URL fetching")
+	}
+	return nil
+}
+
+// Run collects every source in opts and writes the rendered dump to w, or to
+// opts.Output and the clipboard when they are set. The snapshot, if any, is only
+// written once the dump has been.
+func Run(ctx context.Context, opts Options, w io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	var snap *snapshot
+	var err error
+	if opts.Output == "" && !opts.Copy {
+		snap, err = run(ctx, opts, w)
+	} else {
+		var buf bytes.Buffer
+		if snap, err = run(ctx, opts, &buf); err == nil {
+			err = deliver(&opts, buf.Bytes())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return snap.save(opts.Snapshot)
+}
+
+// RunTree writes the directory tree of each of opts.Dirs (the working directory
+// when there are none) without file contents. The name filters, ignore files and
+// generated-file rules apply as in a dump; other inputs are ignored.
+func RunTree(ctx context.Context, opts Options, w io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	renderer, err := opts.renderer()
+	if err != nil {
+		return err
+	}
+	opts.List = true
+	env, err := newEnv(&opts)
+	if err != nil {
+		return err
+	}
+	env.Tree = true
+
+	dirs := opts.Dirs
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var blocks []*Block
+	for _, dir := range dirs {
+		dirBlocks, err := (&DirectorySource{Dir: dir}).Collect(ctx, env)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, dirBlocks[0])
+	}
+	if skipped := env.skipped.sorted(); len(skipped) > 0 {
+		fmt.Fprint(env.Stderr, formatSkipReport(skipped))
+	}
+	return renderer.Render(w, blocks)
+}
+
+// renderer returns opts.Renderer, or the renderer for opts.Format.
+func (opts *Options) renderer() (Renderer, error) {
+	if opts.Renderer != nil {
+		return opts.Renderer, nil
+	}
+	return NewRenderer(opts.Format, opts.XMLTag, opts.Version)
+}
+
+// streams reports whether run writes each source's blocks as soon as it is
+// collected. Only jsonl streams, and not when the budget, --split-*,
+// --redact=fail or --since-snapshot need the whole dump first.
+func (opts *Options) streams() bool {
+	return opts.Format == "jsonl" && opts.Renderer == nil && !opts.List && opts.MaxTokens == 0 &&
+		opts.SplitTokens == 0 && opts.SplitBytes == 0 && opts.Redact != "fail" && !opts.SinceSnapshot
+}
+
+// run collects and renders the dump; opts have been validated. It returns the
+// snapshot to save, or nil without --snapshot.
+func run(ctx context.Context, opts Options, w io.Writer) (*snapshot, error) {
+	renderer, err := opts.renderer()
+	if err != nil {
+		return nil, err
+	}
+	var tok Tokenizer
+	if opts.MaxTokens > 0 || opts.SplitTokens > 0 {
+		var err error
+		tok, err = NewTokenizer(opts.Tokenizer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var rd *redactor
+	if opts.Redact != "off" {
+		var err error
+		rd, err = newRedactor(opts.RedactPatterns, opts.RedactRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := newEnv(&opts)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := opts.sources()
+	if err != nil {
+		return nil, err
+	}
+	var prev *snapshot
+	if opts.SinceSnapshot {
+		if prev, err = loadSnapshot(opts.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	// collect sources concurrently, keeping their blocks in source order
+	results := make([][]*Block, len(sources))
+	errs := make([]error, len(sources))
+	done := make([]chan struct{}, len(sources))
+	for i, src := range sources {
+		done[i] = make(chan struct{})
+		go func(i int, src Source) {
+			defer close(done[i])
+			results[i], errs[i] = src.Collect(ctx, env)
+		}(i, src)
+	}
+	// jsonl is written as each source finishes, in source order, unless
+	// something needs every block before the first can be written
+	stream := opts.streams()
+	var reports []redactionReport
+	var writeErr error
+	for i := range sources {
+		<-done[i]
+		if !stream || errs[i] != nil || ctx.Err() != nil || writeErr != nil {
+			continue
+		}
+		if rd != nil {
+			reports = append(reports, rd.redactBlocks(results[i])...)
+		}
+		writeErr = renderer.Render(w, results[i])
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	var blocks []*Block
+	var failed []error
+	for i := range sources {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		blocks = append(blocks, results[i]...)
+	}
+	// a partial failure is only worth a warning, but if nothing worked, fail
+	if len(failed) > 0 && len(failed) == len(sources) {
+		return nil, errors.Join(failed...)
+	}
+	for _, err := range failed {
+		fmt.Fprintf(env.Stderr, "%v\n", err)
+	}
+	if skipped := env.skipped.sorted(); len(skipped) > 0 {
+		fmt.Fprint(env.Stderr, formatSkipReport(skipped))
+	}
+
+	if opts.List {
+		for _, b := range blocks {
+			if b.Item != nil {
+				if _, err := fmt.Fprintln(w, b.Item.Path); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	var snap *snapshot
+	if opts.Snapshot != "" {
+		snap = newSnapshot()
+	}
+	if prev != nil {
+		blocks, snap = prev.since(blocks)
+	}
+
+	// redact before the budget so tokens are counted on what is written
+	if rd != nil && !stream {
+		reports = rd.redactBlocks(blocks)
+	}
+	if len(reports) > 0 {
+		fmt.Fprint(env.Stderr, formatRedactionReport(reports))
+		if opts.Redact == "fail" {
+			return nil, fmt.Errorf("refusing to write the dump: found secrets in %d items (--redact=fail)", len(reports))
+		}
+	}
+	// snapshot diffs compare redacted content, so no secret reaches the diff
+	if prev != nil && opts.Diff {
+		prev.diff(blocks)
+	}
+
+	// files cut by the budget are not recorded, so they are sent whole next time
+	var truncated map[*Block]bool
+	if opts.MaxTokens > 0 {
+		var total int
+		var actions []budgetAction
+		// the json document around the records takes its share of the budget
+		budget, frame := opts.MaxTokens, 0
+		if jr, ok := renderer.(jsonRenderer); ok {
+			frame = tok.Count(jr.frame(blocks))
+			budget = max(budget-frame, 0)
+		}
+		blocks, total, actions = applyTokenBudget(blocks, budget, tok, renderer)
+		total += frame
+		if len(actions) > 0 {
+			fmt.Fprint(env.Stderr, formatBudgetReport(total, opts.MaxTokens, actions))
+		}
+		truncated = make(map[*Block]bool)
+		for _, a := range actions {
+			truncated[a.block] = a.kept > 0
+		}
+	}
+
+	switch {
+	case stream:
+		// already written
+	case opts.SplitTokens > 0 || opts.SplitBytes > 0:
+		err = writeSplit(&opts, blocks, renderer, tok, env.Stderr)
+	default:
+		err = renderer.Render(w, blocks)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snap != nil {
+		snap.record(blocks, truncated, opts.Diff)
+	}
+	return snap, nil
+}
+
+// writeSplit writes blocks as part files in opts.OutDir and reports them on stderr.
+func writeSplit(opts *Options, blocks []*Block, r Renderer, tok Tokenizer, stderr io.Writer) error {
+	s := &splitter{limit: opts.SplitBytes, measure: func(text string) int { return len(text) }, r: r}
+	unit := "bytes"
+	if opts.SplitTokens > 0 {
+		s.limit, s.measure, unit = opts.SplitTokens, tok.Count, "tokens"
+	}
+	parts, err := s.split(blocks)
+	if err != nil {
+		return err
+	}
+	manifest, err := s.writeParts(opts.OutDir, partExtension(opts.Format, opts.Renderer != nil), unit, parts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stderr, formatSplitReport(opts.OutDir, manifest))
+	return nil
+}
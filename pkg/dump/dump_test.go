@@ -1,20 +1,29 @@
-// main_test.go
-package main
+package dump
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/gobwas/glob"
-	ignore "github.com/sabhiram/go-gitignore"
 )
 
-func TestIsTextFile(t *testing.T) {
+// sniffsText reports whether sniffFile reads the file at path as text.
+func sniffsText(path string) bool {
+	enc, _, err := sniffFile(path)
+	return err == nil && enc != encBinary
+}
+
+func TestSniffFile(t *testing.T) {
 	// Helper to create temp files
 	createTextFile := func(t *testing.T, content string) string {
 		t.Helper()
@@ -49,37 +58,44 @@ func TestIsTextFile(t *testing.T) {
 	// Test cases using t.Run for better organization
 	t.Run("Valid UTF-8 Text", func(t *testing.T) {
 		path := createTextFile(t, "This is a valid text file.\nWith multiple lines.")
-		if !isTextFile(path) {
-			t.Errorf("Expected isTextFile(%q) to be true, got false", path)
+		if !sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) to be true, got false", path)
 		}
 	})
 
 	t.Run("File with Null Bytes", func(t *testing.T) {
 		path := createBinaryFile(t, []byte("This contains a null byte \x00 here."))
-		if isTextFile(path) {
-			t.Errorf("Expected isTextFile(%q) to be false (null byte), got true", path)
+		if sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) to be false (null byte), got true", path)
+		}
+	})
+
+	t.Run("Latin-1 Bytes", func(t *testing.T) {
+		// invalid UTF-8, but reads as Latin-1 / Windows-1252
+		path := createBinaryFile(t, []byte{0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x80})
+		if !sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) to be true (Latin-1), got false", path)
 		}
 	})
 
-	t.Run("Non UTF-8 Bytes", func(t *testing.T) {
-		// Example invalid UTF-8 sequence
-		path := createBinaryFile(t, []byte{0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x80}) // Invalid continuation byte
-		if isTextFile(path) {
-			t.Errorf("Expected isTextFile(%q) to be false (invalid UTF-8), got true", path)
+	t.Run("Non UTF-8 Control Bytes", func(t *testing.T) {
+		path := createBinaryFile(t, []byte{0x68, 0x01, 0x02, 0x03, 0x80})
+		if sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) to be false (invalid UTF-8), got true", path)
 		}
 	})
 
 	t.Run("Empty File", func(t *testing.T) {
 		path := createTextFile(t, "")
-		if !isTextFile(path) {
-			t.Errorf("Expected isTextFile(%q) for empty file to be true, got false", path)
+		if !sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) for empty file to be true, got false", path)
 		}
 	})
 
 	t.Run("Non-existent File", func(t *testing.T) {
 		path := filepath.Join(t.TempDir(), "non_existent_file.txt")
-		if isTextFile(path) {
-			t.Errorf("Expected isTextFile(%q) for non-existent file to be false, got true", path)
+		if sniffsText(path) {
+			t.Errorf("Expected sniffsText(%q) for non-existent file to be false, got true", path)
 		}
 	})
 }
@@ -94,35 +110,49 @@ func TestFormatOutput(t *testing.T) {
 	}{
 		{
 			name:     "XML format",
-			output:   Item{path: "src/main.go", content: "package main\nfunc main() {}\n"},
+			output:   Item{Path: "src/main.go", Content: "package main\nfunc main() {}\n"},
 			format:   "xml",
 			tag:      "document",
 			expected: "<document path='src/main.go'>\npackage main\nfunc main() {}\n</document>\n",
 		},
 		{
 			name:     "Markdown format",
-			output:   Item{path: "src/main.go", content: "package main\nfunc main() {}\n"},
+			output:   Item{Path: "src/main.go", Content: "package main\nfunc main() {}\n"},
 			format:   "md",
 			tag:      "document", // Tag is ignored for md format
 			expected: "```src/main.go\npackage main\nfunc main() {}\n```\n",
 		},
 		{
 			name:     "XML with custom tag",
-			output:   Item{path: "test.txt", content: "hello world\n"},
+			output:   Item{Path: "test.txt", Content: "hello world\n"},
 			format:   "xml",
 			tag:      "source",
 			expected: "<source path='test.txt'>\nhello world\n</source>\n",
 		},
 		{
 			name:     "URL with XML format",
-			output:   Item{path: "https://example.com", content: "web content\n"},
+			output:   Item{Path: "https://example.com", Content: "web content\n"},
 			format:   "xml",
 			tag:      "document",
-			expected: "<document url='https://example.com'>\nweb content\n</web>\n",
+			expected: "<document url='https://example.com'>\nweb content\n</document>\n",
+		},
+		{
+			name:     "Strict XML escapes attributes",
+			output:   Item{Path: "it's <a&b>.txt", Content: "x\n"},
+			format:   "xml-strict",
+			tag:      "document",
+			expected: "<document path='it&apos;s &lt;a&amp;b&gt;.txt'>\n<![CDATA[x\n]]>\n</document>\n",
+		},
+		{
+			name:     "Strict XML splits CDATA terminator",
+			output:   Item{Path: "https://example.com", Content: "a]]>b\n"},
+			format:   "xml-strict",
+			tag:      "web",
+			expected: "<web url='https://example.com'>\n<![CDATA[a]]]]><![CDATA[>b\n]]>\n</web>\n",
 		},
 		{
 			name:     "URL with Markdown format",
-			output:   Item{path: "https://example.com", content: "web content\n"},
+			output:   Item{Path: "https://example.com", Content: "web content\n"},
 			format:   "md",
 			tag:      "file",
 			expected: "```https://example.com\nweb content\n```\n",
@@ -142,7 +172,7 @@ func TestFormatOutput(t *testing.T) {
 func TestFormatTmuxItem(t *testing.T) {
 	t.Run("XML format", func(t *testing.T) {
 		ti := TmuxPaneItem{
-			id: "%1", session: "s", window: "0", pane: "1", content: "line1\nline2\n",
+			ID: "%1", Session: "s", Window: "0", Pane: "1", Content: "line1\nline2\n",
 		}
 		got := formatTmuxItem(ti, "xml")
 		expected := "<tmux_pane id='%1' session='s' window='0' pane='1'>\nline1\nline2\n</tmux_pane>\n"
@@ -153,7 +183,7 @@ func TestFormatTmuxItem(t *testing.T) {
 
 	t.Run("Markdown format", func(t *testing.T) {
 		ti := TmuxPaneItem{
-			id: "%2", session: "dev", window: "1", pane: "0", content: "echo hi\n",
+			ID: "%2", Session: "dev", Window: "1", Pane: "0", Content: "echo hi\n",
 		}
 		got := formatTmuxItem(ti, "md")
 		expected := "```shell\n# tmux-pane: id='%2' session='dev' window='1' pane='0'\n\n" +
@@ -164,6 +194,102 @@ func TestFormatTmuxItem(t *testing.T) {
 	})
 }
 
+// xmlElement is a top-level element recovered from xml-strict output.
+type xmlElement struct {
+	name    string
+	attrs   map[string]string
+	content string
+}
+
+// parseStrictXML parses a sequence of xml-strict elements back into their
+// attributes and original content.
+func parseStrictXML(t *testing.T, s string) []xmlElement {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader("<root>" + s + "</root>"))
+	var elems []xmlElement
+	var cur *xmlElement
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid xml-strict output: %v\n%s", err, s)
+		}
+		switch tk := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				cur = &xmlElement{name: tk.Name.Local, attrs: map[string]string{}}
+				for _, a := range tk.Attr {
+					cur.attrs[a.Name.Local] = a.Value
+				}
+			}
+		case xml.CharData:
+			if cur != nil {
+				cur.content += string(tk)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				// the renderer puts the CDATA section on its own line
+				cur.content = strings.TrimSuffix(strings.TrimPrefix(cur.content, "\n"), "\n")
+				elems = append(elems, *cur)
+				cur = nil
+			}
+			depth--
+		}
+	}
+	return elems
+}
+
+func TestStrictXMLRoundTrip(t *testing.T) {
+	items := []Item{
+		{Path: `quote'd "name".go`, Content: "if a < b && c > d {\n}\n"},
+		{Path: "nested.xml", Content: "<document path='fake'>\ninjected\n</document>\n"},
+		{Path: "cdata.txt", Content: "one ]]> two ]]]]> three <![CDATA[ four"},
+		{Path: "https://example.com/?a=1&b=2", Content: "web\n"},
+		{Path: "empty.txt", Content: ""},
+	}
+	pane := TmuxPaneItem{ID: "%1", Session: "dev's", Window: "0", Pane: "1", Content: "$ echo ']]>'\n"}
+	// XML 1.0 has no way to write these, escaped or not
+	control := Item{Path: "ctrl\x01.log", Content: "\x1b[31mred\x1b[0m\x01\x08\x0b\x0c\tok\xff\n"}
+
+	var out strings.Builder
+	for _, item := range items {
+		out.WriteString(formatItem(item, "xml-strict", "document"))
+	}
+	out.WriteString(formatTmuxItem(pane, "xml-strict"))
+	out.WriteString(formatItem(control, "xml-strict", "document"))
+
+	elems := parseStrictXML(t, out.String())
+	if len(elems) != len(items)+2 {
+		t.Fatalf("Expected %d elements, got %d", len(items)+2, len(elems))
+	}
+	for i, item := range items {
+		got := elems[i]
+		attr := "path"
+		if isURL(item.Path) {
+			attr = "url"
+		}
+		if got.name != "document" || got.attrs[attr] != item.Path {
+			t.Errorf("Element %d: got <%s %v>, expected <document %s=%q>", i, got.name, got.attrs, attr, item.Path)
+		}
+		if got.content != item.Content {
+			t.Errorf("Element %d content = %q, expected %q", i, got.content, item.Content)
+		}
+	}
+	last := elems[len(items)]
+	if last.name != "tmux_pane" || last.attrs["session"] != pane.Session || last.content != pane.Content {
+		t.Errorf("Tmux pane did not round-trip: %+v", last)
+	}
+	replaced := elems[len(items)+1]
+	expected := "\uFFFD[31mred\uFFFD[0m\uFFFD\uFFFD\uFFFD\uFFFD\tok\uFFFD\n"
+	if replaced.attrs["path"] != "ctrl\uFFFD.log" || replaced.content != expected {
+		t.Errorf("Control characters: got path %q content %q, expected content %q", replaced.attrs["path"], replaced.content, expected)
+	}
+}
+
 func TestWriteContents(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -312,7 +438,7 @@ Line 5`
 	}
 
 	t.Run("No Filter", func(t *testing.T) {
-		output, err := dumpFile(absolutePath, relativePath, nil) // No filter
+		output, err := dumpFile(absolutePath, relativePath, &Env{}) // No filter
 		if err != nil {
 			t.Errorf("dumpFile failed unexpectedly: %v", err)
 			return
@@ -322,18 +448,18 @@ Line 5`
 			t.Fatal("dumpFile returned nil output")
 		}
 
-		if output.path != relativePath {
-			t.Errorf("Expected path %q, got %q", relativePath, output.path)
+		if output.Path != relativePath {
+			t.Errorf("Expected path %q, got %q", relativePath, output.Path)
 		}
 
 		// Compare content (remove trailing newline that dumpFile adds)
 		expectedContent := content + "\n"
-		compareContent(t, output.content, expectedContent)
+		compareContent(t, output.Content, expectedContent)
 	})
 
 	t.Run("With Filter", func(t *testing.T) {
 		filterRegex := regexp.MustCompile(`secret`)
-		output, err := dumpFile(absolutePath, relativePath, filterRegex)
+		output, err := dumpFile(absolutePath, relativePath, &Env{Filter: filterRegex})
 		if err != nil {
 			t.Errorf("dumpFile with filter failed unexpectedly: %v", err)
 			return
@@ -343,19 +469,19 @@ Line 5`
 			t.Fatal("dumpFile returned nil output")
 		}
 
-		if output.path != relativePath {
-			t.Errorf("Expected path %q, got %q", relativePath, output.path)
+		if output.Path != relativePath {
+			t.Errorf("Expected path %q, got %q", relativePath, output.Path)
 		}
 
 		expectedContent := `Line 1
 Line 3
 Line 5
 `
-		compareContent(t, output.content, expectedContent)
+		compareContent(t, output.Content, expectedContent)
 	})
 
 	t.Run("Non-existent file", func(t *testing.T) {
-		_, err := dumpFile(filepath.Join(t.TempDir(), "non_existent"), "rel/path", nil)
+		_, err := dumpFile(filepath.Join(t.TempDir(), "non_existent"), "rel/path", &Env{})
 		if err == nil {
 			t.Error("Expected dumpFile to return an error for non-existent file, but got nil")
 		}
@@ -363,7 +489,7 @@ Line 5
 }
 
 // Helper function for tests that simulates file processing
-func processFile(path string, baseDir string, gitIgnore *ignore.GitIgnore, filter *regexp.Regexp) {
+func processFile(path string, baseDir string, gitIgnore *ignoreMatcher, filter *regexp.Regexp) {
 	relPath, err := filepath.Rel(baseDir, path)
 	if err != nil {
 		return
@@ -375,12 +501,12 @@ func processFile(path string, baseDir string, gitIgnore *ignore.GitIgnore, filte
 	}
 
 	// Only dump text files
-	if !isTextFile(path) {
+	if !sniffsText(path) {
 		return
 	}
 
 	// Dump file contents (without error checking for test simplicity)
-	_, _ = dumpFile(path, relPath, filter)
+	_, _ = dumpFile(path, relPath, &Env{Filter: filter})
 }
 
 func TestCompilePatterns(t *testing.T) {
@@ -573,3 +699,237 @@ func TestConcurrentProcessing(t *testing.T) {
 		t.Errorf("data.txt should not have been matched, but was")
 	}
 }
+
+// staticSource is a custom Source that returns fixed items.
+type staticSource struct {
+	items []*Item
+}
+
+func (s *staticSource) Name() string { return "static" }
+
+func (s *staticSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	var blocks []*Block
+	for _, item := range s.items {
+		content, err := env.FilterContent(item.Content)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, &Block{Item: &Item{Path: item.Path, Content: content}})
+	}
+	return blocks, nil
+}
+
+// failingSource is a custom Source that always fails.
+type failingSource struct{}
+
+func (failingSource) Name() string { return "failing" }
+
+func (failingSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	return nil, errors.New("source failed")
+}
+
+// noisySource is a custom Source that only writes diagnostics.
+type noisySource struct{}
+
+func (noisySource) Name() string { return "noisy" }
+
+func (noisySource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	for i := 0; i < 100; i++ {
+		io.WriteString(env.Stderr, "warning\n")
+	}
+	return nil, nil
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n// drop me\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Directory and custom source", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Dirs = []string{dir}
+		opts.Filter = "^//"
+		opts.Sources = []Source{&staticSource{items: []*Item{{Path: "custom", Content: "keep\n// drop\n"}}}}
+
+		var buf bytes.Buffer
+		if err := Run(context.Background(), opts, &buf); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		base := filepath.Base(dir)
+		expected := "<document path='" + filepath.Join(base, "a.go") + "' filtered='true'>\npackage a\n</document>\n" +
+			"<document path='custom'>\nkeep\n</document>\n"
+		if buf.String() != expected {
+			t.Errorf("Run output = %q, expected %q", buf.String(), expected)
+		}
+	})
+
+	t.Run("Custom renderer", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Sources = []Source{&staticSource{items: []*Item{{Path: "x", Content: "y\n"}}}}
+		opts.Renderer = textRenderer{format: "md"}
+
+		var buf bytes.Buffer
+		if err := Run(context.Background(), opts, &buf); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if buf.String() != "```x\ny\n```\n" {
+			t.Errorf("Expected markdown output, got %q", buf.String())
+		}
+	})
+
+	t.Run("List mode", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Dirs = []string{dir}
+		opts.List = true
+
+		var buf bytes.Buffer
+		if err := Run(context.Background(), opts, &buf); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if buf.String() != filepath.Join(filepath.Base(dir), "a.go")+"\n" {
+			t.Errorf("Unexpected list output %q", buf.String())
+		}
+	})
+
+	t.Run("Partial failure is a warning", func(t *testing.T) {
+		opts := DefaultOptions()
+		var stderr bytes.Buffer
+		opts.Stderr = &stderr
+		opts.Sources = []Source{failingSource{}, &staticSource{items: []*Item{{Path: "ok", Content: "ok\n"}}}}
+
+		var buf bytes.Buffer
+		if err := Run(context.Background(), opts, &buf); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if !strings.Contains(stderr.String(), "source failed") {
+			t.Errorf("Expected failure on stderr, got %q", stderr.String())
+		}
+	})
+
+	// sources run concurrently, so a plain buffer is only safe if Run serializes
+	// the writes (go test -race)
+	t.Run("Concurrent diagnostics", func(t *testing.T) {
+		opts := DefaultOptions()
+		var stderr bytes.Buffer
+		opts.Stderr = &stderr
+		opts.Sources = []Source{noisySource{}, noisySource{}}
+		if err := Run(context.Background(), opts, io.Discard); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if n := strings.Count(stderr.String(), "\n"); n != 200 {
+			t.Errorf("Expected 200 lines on stderr, got %d", n)
+		}
+	})
+
+	t.Run("Total failure is an error", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Sources = []Source{failingSource{}}
+		if err := Run(context.Background(), opts, io.Discard); err == nil {
+			t.Error("Expected error when every source fails")
+		}
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		opts := DefaultOptions()
+		opts.Dirs = []string{dir}
+		if err := Run(ctx, opts, io.Discard); err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestOptionsInputs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []input
+	}{
+		{
+			name:     "Defaults to current directory",
+			opts:     Options{},
+			expected: []input{{"dir", "."}},
+		},
+		{
+			name: "Default order",
+			opts: Options{Dirs: []string{"a", "b"}, URLs: []string{"https://x"}, Commands: []string{"ls"}, ReadStdin: true, StdinLabel: "in"},
+			expected: []input{
+				{"dir", "a"}, {"dir", "b"}, {"stdin", "in"}, {"cmd", "ls"}, {"url", "https://x"},
+			},
+		},
+		{
+			name: "Command-line order",
+			opts: Options{
+				Dirs:     []string{"a", "b"},
+				URLs:     []string{"https://x"},
+				Commands: []string{"ls", "pwd"},
+				Order:    []string{"cmd", "dir", "url", "cmd"},
+			},
+			expected: []input{
+				{"cmd", "ls"}, {"dir", "a"}, {"url", "https://x"}, {"cmd", "pwd"}, {"dir", "b"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.opts.inputs(); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("inputs() = %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSourcesGroupConsecutiveInputs(t *testing.T) {
+	opts := Options{
+		Commands: []string{"a", "b", "c"},
+		Dirs:     []string{"src"},
+		Order:    []string{"cmd", "cmd", "dir", "cmd"},
+	}
+	sources, err := opts.sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, src := range sources {
+		switch s := src.(type) {
+		case *CommandSource:
+			names = append(names, "cmd "+strings.Join(s.Commands, ","))
+		default:
+			names = append(names, s.Name())
+		}
+	}
+	expected := []string{"cmd a,b", "directory src", "cmd c"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("sources = %q, expected %q", names, expected)
+	}
+}
+
+func TestDirectoryTreeOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	for _, name := range []string{"b/y.txt", "a/z/q.txt", "a/x.txt", "a-c.txt", "c/d/e/f.txt"} {
+		files[name] = name + "\n"
+	}
+	writeFiles(t, dir, files)
+	expected := "├── a\n│   ├── x.txt\n│   └── z\n│       └── q.txt\n├── a-c.txt\n├── b\n│   └── y.txt\n" +
+		"└── c\n    └── d\n        └── e\n            └── f.txt\n"
+
+	// directory children used to come from map iteration; run a few times
+	for i := 0; i < 5; i++ {
+		env, err := newEnv(&Options{Tree: true, Stderr: io.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := (&DirectorySource{Dir: dir}).Collect(context.Background(), env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := *blocks[0].Tree
+		root.Name = "."
+		if got := formatTreeNode(&root, "", true); got != expected {
+			t.Fatalf("Tree = %q, expected %q", got, expected)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+type TreeNode struct {
+	Name     string
+	Path     string
+	IsDir    bool
+	Children []*TreeNode
+}
+
+func compilePatterns(patterns []string) ([]glob.Glob, error) {
+	var globs []glob.Glob
+	for _, p := range patterns {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+func matchesAny(path string, globs []glob.Glob) bool {
+	for _, g := range globs {
+		if g.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFileFilters applies the glob and extension filters, which combine with OR semantics.
+// include when:
+// - no filters specified, or
+// - matches any provided glob, or
+// - matches any provided extension
+func matchesFileFilters(relPath string, globs []glob.Glob, extSet map[string]struct{}) bool {
+	if len(globs) == 0 && len(extSet) == 0 {
+		return true
+	}
+	if len(globs) > 0 && matchesAny(relPath, globs) {
+		return true
+	}
+	if len(extSet) > 0 {
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(relPath)), ".")
+		if _, ok := extSet[ext]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterContent applies the line filter regex to a block of text.
+func filterContent(r io.Reader, filter *regexp.Regexp) (string, error) {
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	// Increase max token size to handle very long lines (default ~64KB)
+	const maxLineSize = 10 * 1024 * 1024 // 10 MiB
+	scanner.Buffer(make([]byte, 1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if filter != nil && filter.MatchString(line) {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func dumpFile(path, displayPath string, env *Env) (*Item, error) {
+	cb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	enc := fileEncoding(cb)
+	if enc == encBinary && env.binary != "" && env.binary != "skip" {
+		// binary content has no lines to select
+		if env.contains != nil {
+			return nil, errNoMatch
+		}
+		item := &Item{Path: displayPath, Content: formatBinary(cb, env.binary), Binary: env.binary, Meta: env.fileMeta(path, cb, true), file: path}
+		if env.snapshotFile != "" {
+			item.state = &snapshotEntry{Hash: contentHash(cb)}
+		}
+		return item, nil
+	}
+	text := decodeText(cb, enc)
+	content, transforms, err := env.fileContent(path, text)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{
+		Path:        displayPath,
+		Content:     content,
+		LineNumbers: env.lineNumbers,
+		Meta:        env.fileMeta(path, cb, false),
+		Transforms:  transforms,
+		file:        path,
+	}
+	if env.snapshotFile != "" {
+		item.state = &snapshotEntry{Hash: contentHash(cb)}
+	}
+	return item, nil
+}
+
+// collectFile returns the item for a file that passed the name filters, or nil
+// when --contains excludes it or it cannot be read. In list mode the item carries
+// only its path, though the content is still read when --contains needs it.
+func (e *Env) collectFile(path, displayPath string) *Item {
+	if e.List && e.contains == nil {
+		return &Item{Path: displayPath, file: path}
+	}
+	item, err := dumpFile(path, displayPath, e)
+	if errors.Is(err, errNoMatch) {
+		return nil
+	}
+	if err != nil {
+		e.skip(displayPath, err.Error())
+		return nil
+	}
+	if e.List {
+		return &Item{Path: displayPath, file: path}
+	}
+	return item
+}
+
+// DirectorySource walks a directory, respecting .gitignore and the glob, extension
+// and ignore filters.
+type DirectorySource struct {
+	Dir string
+
+	changes *gitChanges // set for git-aware mode
+}
+
+func (s *DirectorySource) Name() string {
+	return "directory " + s.Dir
+}
+
+func (s *DirectorySource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	absDir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory %q: %w", s.Dir, err)
+	}
+	if !env.allowed(absDir) {
+		return nil, fmt.Errorf("directory %q is outside the allowed roots", s.Dir)
+	}
+
+	ignores, err := buildIgnoreList(absDir, env.opts.Ignore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ignore list for %q: %w", s.Dir, err)
+	}
+
+	var items []*Item
+	var dirTree *TreeNode
+	if env.Tree {
+		dirTree = &TreeNode{
+			Name:     filepath.Base(absDir),
+			Path:     absDir,
+			IsDir:    true,
+			Children: []*TreeNode{},
+		}
+	}
+
+	if err := processDirectory(ctx, absDir, env, ignores, s.changes, &items, dirTree); err != nil {
+		return nil, fmt.Errorf("failed to process directory %q: %w", s.Dir, err)
+	}
+	if err := sortItems(items, env.opts.Sort, absDir); err != nil {
+		return nil, err
+	}
+
+	var blocks []*Block
+	if dirTree != nil {
+		blocks = append(blocks, &Block{Tree: dirTree})
+	}
+	for _, item := range items {
+		blocks = append(blocks, &Block{Item: item})
+	}
+	return blocks, nil
+}
+
+// processDirectory walks baseDir and appends an item for every matching file. In
+// list mode the items carry only their path.
+func processDirectory(
+	ctx context.Context, baseDir string, env *Env, ignores *ignoreMatcher, changes *gitChanges,
+	items *[]*Item, treeRoot *TreeNode,
+) error {
+	parentDir := filepath.Base(baseDir)
+
+	var nodeMap map[string]*TreeNode
+	if treeRoot != nil {
+		nodeMap = make(map[string]*TreeNode)
+		nodeMap[baseDir] = treeRoot
+	}
+
+	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return nil
+		}
+
+		if path != baseDir && (ignores.ignored(relPath, d.IsDir()) || d.IsDir() && path == env.outDir) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() && path != baseDir && !env.opts.IncludeGenerated {
+			if _, ok := vendoredDirNames[d.Name()]; ok {
+				env.skip(filepath.Join(parentDir, relPath)+string(filepath.Separator), "vendored directory")
+				return filepath.SkipDir
+			}
+		}
+
+		// handle directory nodes for tree (if tree building is enabled)
+		if d.IsDir() {
+			// nested ignore files apply to everything below their directory
+			ignores.enterDir(relPath)
+			if treeRoot != nil && path != baseDir {
+				node := &TreeNode{
+					Name:     d.Name(),
+					Path:     path,
+					IsDir:    true,
+					Children: []*TreeNode{},
+				}
+				nodeMap[path] = node
+				// WalkDir visits entries in lexical order, so attaching nodes as
+				// they are seen keeps the tree sorted
+				if parentNode, exists := nodeMap[filepath.Dir(path)]; exists {
+					parentNode.Children = append(parentNode.Children, node)
+				}
+			}
+			return nil
+		}
+
+		if !matchesFileFilters(relPath, env.globs, env.extSet) || env.written[path] {
+			return nil
+		}
+
+		// git-aware mode: only files git reports as changed
+		if changes != nil && !changes.contains(relPath) {
+			return nil
+		}
+
+		displayPath := filepath.Join(parentDir, relPath)
+		info, err := d.Info()
+		if err != nil {
+			env.skip(displayPath, err.Error())
+			return nil
+		}
+		if reason := env.skipReason(path, filepath.ToSlash(relPath), info, ignores); reason != "" {
+			env.skip(displayPath, reason)
+			return nil
+		}
+
+		output := env.collectFile(path, displayPath)
+		if output == nil {
+			return nil
+		}
+		if changes != nil && changes.withDiff && !env.List {
+			output.Diff, err = changes.fileDiff(relPath)
+			if err != nil {
+				fmt.Fprintf(env.Stderr, "failed to diff %s: %v\n", displayPath, err)
+			}
+		}
+		*items = append(*items, output)
+
+		// add file node to tree (if tree building is enabled)
+		if treeRoot != nil {
+			fileNode := &TreeNode{
+				Name:  d.Name(),
+				Path:  path,
+				IsDir: false,
+			}
+
+			parentPath := filepath.Dir(path)
+			if parentNode, exists := nodeMap[parentPath]; exists {
+				parentNode.Children = append(parentNode.Children, fileNode)
+			}
+		}
+
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,181 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lockFileNames are dependency lock files, skipped unless --include-generated.
+var lockFileNames = map[string]struct{}{
+	"go.sum": {}, "go.work.sum": {},
+	"package-lock.json": {}, "npm-shrinkwrap.json": {}, "yarn.lock": {}, "pnpm-lock.yaml": {},
+	"bun.lock": {}, "bun.lockb": {}, "deno.lock": {},
+	"Cargo.lock": {}, "poetry.lock": {}, "Pipfile.lock": {}, "uv.lock": {}, "pdm.lock": {},
+	"Gemfile.lock": {}, "composer.lock": {}, "mix.lock": {}, "pubspec.lock": {},
+	"Podfile.lock": {}, "Package.resolved": {}, "packages.lock.json": {},
+	"gradle.lockfile": {}, "flake.lock": {}, "MODULE.bazel.lock": {},
+}
+
+// vendoredDirNames are directories of third-party code, skipped as a whole.
+var vendoredDirNames = map[string]struct{}{
+	"vendor": {}, "node_modules": {}, "bower_components": {}, "third_party": {},
+}
+
+// generatedNamePatterns match the names of files that tools generate.
+var generatedNamePatterns = []string{
+	"*.pb.go", "*.pb.gw.go", "*_gen.go", "*.gen.go", "*_generated.go",
+	"*_pb2.py", "*_pb2.pyi", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	"*.js.map", "*.css.map",
+}
+
+// minifiableExts are checked for minified content.
+var minifiableExts = map[string]struct{}{".js": {}, ".mjs": {}, ".cjs": {}, ".css": {}}
+
+// generatedHeaderRgx matches the comment that marks a generated file: Go's
+// "Code generated ... DO NOT EDIT." convention, which other tools follow in
+// their own comment syntax, or a comment starting with @generated.
+var generatedHeaderRgx = regexp.MustCompile(`(?m)^\s*(//|#|--|;+|/?\*+|<!--)\s*(Code generated .* DO NOT EDIT\.?|@generated\b)`)
+
+// generatedReason reports why the file at relPath (a slash path) is generated,
+// vendored or a lock file, or returns "" if it is none of these. sample is the
+// start of its content and attrs, if not nil, holds the .gitattributes rules.
+func generatedReason(relPath string, sample []byte, attrs *ignoreMatcher) string {
+	name := path.Base(relPath)
+	// linguist attributes override the built-in rules either way
+	if attrs != nil {
+		if set, ok := attrs.attr(relPath, "linguist-generated"); ok {
+			if set {
+				return "generated (linguist-generated)"
+			}
+			return ""
+		}
+		if set, ok := attrs.attr(relPath, "linguist-vendored"); ok {
+			if set {
+				return "vendored (linguist-vendored)"
+			}
+			return ""
+		}
+	}
+	for _, dir := range strings.Split(path.Dir(relPath), "/") {
+		if _, ok := vendoredDirNames[dir]; ok {
+			return "vendored (" + dir + "/)"
+		}
+	}
+	if _, ok := lockFileNames[name]; ok {
+		return "lock file"
+	}
+	for _, p := range generatedNamePatterns {
+		if ok, _ := path.Match(p, name); ok {
+			return "generated (" + p + ")"
+		}
+	}
+	if strings.Contains(name, ".min.") {
+		return "minified"
+	}
+	if generatedHeaderRgx.Match(sample) {
+		return "generated (header comment)"
+	}
+	if _, ok := minifiableExts[path.Ext(name)]; ok && isMinified(sample) {
+		return "minified"
+	}
+	return ""
+}
+
+// isMinified reports whether sample has the long lines of minified code, using
+// linguist's threshold of more than 110 characters per line on average.
+func isMinified(sample []byte) bool {
+	lines := bytes.Count(sample, []byte("\n")) + 1
+	return len(sample) > 1000 && len(sample)/lines > 110
+}
+
+// attrPattern is one line of a .gitattributes file, reduced to the linguist
+// attributes: true when set, false when unset.
+type attrPattern struct {
+	ignorePattern
+	attrs map[string]bool
+}
+
+// parseAttributesLine parses a .gitattributes line relative to base, reporting
+// false for lines without linguist attributes.
+func parseAttributesLine(line, base string) (attrPattern, bool) {
+	fields := strings.Fields(line)
+	// attribute files do not support negative patterns
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "!") {
+		return attrPattern{}, false
+	}
+	p, ok := parseIgnoreLine(fields[0], base)
+	if !ok {
+		return attrPattern{}, false
+	}
+	ap := attrPattern{ignorePattern: p, attrs: make(map[string]bool)}
+	for _, f := range fields[1:] {
+		name, value, hasValue := strings.Cut(f, "=")
+		set := true
+		if strings.HasPrefix(name, "-") {
+			name, set = name[1:], false
+		}
+		if name != "linguist-generated" && name != "linguist-vendored" {
+			continue
+		}
+		if hasValue {
+			set = value == "true" || value == "1"
+		}
+		ap.attrs[name] = set
+	}
+	return ap, len(ap.attrs) > 0
+}
+
+// readAttributesFile parses the .gitattributes file at name, returning nothing
+// if it cannot be read.
+func readAttributesFile(name, base string) []attrPattern {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []attrPattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseAttributesLine(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// attr looks up a linguist attribute of the file at relPath (relative to the
+// walked directory) in .git/info/attributes and the .gitattributes files from
+// the repository root down. The last matching line decides; ok is false when
+// none mentions the attribute.
+func (m *ignoreMatcher) attr(relPath, name string) (set, ok bool) {
+	rel := m.rel(relPath)
+	check := func(patterns []attrPattern) {
+		for i := range patterns {
+			if v, has := patterns[i].attrs[name]; has && patterns[i].matches(rel, false) {
+				set, ok = v, true
+			}
+		}
+	}
+
+	check(m.dirAttrs[""])
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			m.loadDir(rel[:i])
+			check(m.dirAttrs[rel[:i]])
+		}
+	}
+	// info/attributes takes precedence over the files in the tree
+	check(m.globalAttrs)
+	return set, ok
+}
+
+// loadAttributes reads the .gitattributes file of dir, a slash path relative to root.
+func (m *ignoreMatcher) loadAttributes(dir string) {
+	m.dirAttrs[dir] = readAttributesFile(filepath.Join(m.root, filepath.FromSlash(dir), ".gitattributes"), dir)
+}
@@ -0,0 +1,97 @@
+package dump
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedReason(t *testing.T) {
+	testCases := []struct {
+		path     string
+		sample   string
+		expected string
+	}{
+		{"main.go", "package main\n", ""},
+		{"go.sum", "", "lock file"},
+		{"web/package-lock.json", "{}", "lock file"},
+		{"api/v1/api.pb.go", "package v1\n", "generated (*.pb.go)"},
+		{"kind_string.go", "// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n\npackage x\n", "generated (header comment)"},
+		{"schema.py", "# Code generated by tool. DO NOT EDIT.\n", "generated (header comment)"},
+		{"lib.rs", "// @generated\nfn main() {}\n", "generated (header comment)"},
+		{"notes.go", "package x\n\n// the @generated marker is mentioned here\n", ""},
+		{"vendor/github.com/x/y.go", "package y\n", "vendored (vendor/)"},
+		{"static/app.min.js", "", "minified"},
+		{"static/bundle.js", "var a=1;" + string(make([]byte, 2000)), "minified"},
+		{"static/app.js", "function f() {\n  return 1;\n}\n", ""},
+	}
+	for _, tc := range testCases {
+		if got := generatedReason(tc.path, []byte(tc.sample), nil); got != tc.expected {
+			t.Errorf("generatedReason(%s) = %q, expected %q", tc.path, got, tc.expected)
+		}
+	}
+}
+
+func TestDirectoryGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":             "package main\n",
+		"go.sum":              "example.com/x v1.0.0 h1:abc=\n",
+		"enum_string.go":      "// Code generated by stringer; DO NOT EDIT.\n\npackage main\n",
+		"api/openapi.go":      "package api\n",
+		"api/keep.pb.go":      "package api\n",
+		"vendor/x/x.go":       "package x\n",
+		".gitattributes":      "api/openapi.go linguist-generated\n*.pb.go -linguist-generated\n",
+		"docs/spec/.keep.txt": "kept\n",
+	}
+	writeFiles(t, dir, files)
+
+	for _, include := range []bool{false, true} {
+		env, err := newEnv(&Options{List: true, IncludeGenerated: include, Stderr: io.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := (&DirectorySource{Dir: dir}).Collect(context.Background(), env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]bool{}
+		for _, b := range blocks {
+			rel, _ := filepath.Rel(filepath.Base(dir), b.Item.Path)
+			got[filepath.ToSlash(rel)] = true
+		}
+
+		kept := []string{".gitattributes", "main.go", "api/keep.pb.go", "docs/spec/.keep.txt"}
+		skipped := map[string]string{
+			"go.sum":         "lock file",
+			"enum_string.go": "generated (header comment)",
+			"api/openapi.go": "generated (linguist-generated)",
+			"vendor/":        "vendored directory",
+		}
+		for _, name := range kept {
+			if !got[name] {
+				t.Errorf("include=%v: expected %s to be dumped, got %v", include, name, got)
+			}
+		}
+		if include {
+			if len(got) != len(files) || len(env.skipped.sorted()) != 0 {
+				t.Errorf("Expected every file with --include-generated, got %v (skipped %v)", got, env.skipped.sorted())
+			}
+			continue
+		}
+		reasons := map[string]string{}
+		for _, f := range env.skipped.sorted() {
+			rel, _ := filepath.Rel(filepath.Base(dir), f.path)
+			if f.path[len(f.path)-1] == filepath.Separator {
+				rel += "/"
+			}
+			reasons[filepath.ToSlash(rel)] = f.reason
+		}
+		for name, reason := range skipped {
+			if got[name] || reasons[name] != reason {
+				t.Errorf("%s: dumped=%v reason=%q, expected skipped as %q", name, got[name], reasons[name], reason)
+			}
+		}
+	}
+}
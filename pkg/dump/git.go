@@ -0,0 +1,119 @@
+package dump
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitChanges restricts a directory walk to the files git reports as changed.
+type gitChanges struct {
+	dir       string
+	sets      []changeSet
+	paths     map[string]struct{}
+	untracked map[string]struct{} // the paths that are untracked files
+	withDiff  bool                // attach the unified diff to each dumped file
+}
+
+// changeSet is one of --changed-since, --staged and --unstaged.
+type changeSet struct {
+	args      []string // `git diff` arguments
+	untracked bool     // also list untracked files, which `git diff` leaves out
+}
+
+// gitChangeSets returns the change sets for --changed-since, --staged and
+// --unstaged, or nil when none of them is set.
+func gitChangeSets(changedSince string, staged, unstaged bool) ([]changeSet, error) {
+	var sets []changeSet
+	if changedSince != "" {
+		if strings.HasPrefix(changedSince, "-") {
+			return nil, fmt.Errorf("invalid --changed-since ref %q", changedSince)
+		}
+		// "--" keeps the ref from being read as a path
+		sets = append(sets, changeSet{args: []string{changedSince, "--"}})
+	}
+	if staged {
+		sets = append(sets, changeSet{args: []string{"--cached", "--"}})
+	}
+	if unstaged {
+		// new files are unstaged changes too, as in `git status`
+		sets = append(sets, changeSet{args: []string{"--"}, untracked: true})
+	}
+	return sets, nil
+}
+
+// loadGitChanges lists the files under dir that differ for any of sets.
+// Deleted files are skipped since there is nothing on disk to dump.
+func loadGitChanges(dir string, sets []changeSet, withDiff bool) (*gitChanges, error) {
+	if _, err := runCmd("git", "-C", dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", dir, err)
+	}
+
+	changes := &gitChanges{
+		dir:       dir,
+		sets:      sets,
+		paths:     make(map[string]struct{}),
+		untracked: make(map[string]struct{}),
+		withDiff:  withDiff,
+	}
+	for _, set := range sets {
+		args := append([]string{"-C", dir, "diff", "--name-only", "--relative", "-z", "--diff-filter=d"}, set.args...)
+		out, err := runCmd("git", args...)
+		if err != nil {
+			return nil, fmt.Errorf("git diff failed in %s: %w", dir, err)
+		}
+		for _, p := range strings.Split(out, "\x00") {
+			if p != "" {
+				changes.paths[p] = struct{}{}
+			}
+		}
+		if !set.untracked {
+			continue
+		}
+		out, err = runCmd("git", "-C", dir, "ls-files", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, fmt.Errorf("git ls-files failed in %s: %w", dir, err)
+		}
+		for _, p := range strings.Split(out, "\x00") {
+			if p != "" {
+				changes.paths[p] = struct{}{}
+				changes.untracked[p] = struct{}{}
+			}
+		}
+	}
+	return changes, nil
+}
+
+// contains reports whether relPath (relative to the walked directory) has changes.
+func (c *gitChanges) contains(relPath string) bool {
+	_, ok := c.paths[filepath.ToSlash(relPath)]
+	return ok
+}
+
+// fileDiff returns the unified diff of relPath for every change set. An untracked
+// file, which git has nothing to diff against, is shown as added in full.
+func (c *gitChanges) fileDiff(relPath string) (string, error) {
+	path := filepath.ToSlash(relPath)
+	if _, ok := c.untracked[path]; ok {
+		data, err := os.ReadFile(filepath.Join(c.dir, relPath))
+		if err != nil {
+			return "", err
+		}
+		return unifiedDiff("/dev/null", "b/"+path, "", string(data)), nil
+	}
+	var sb strings.Builder
+	for _, set := range c.sets {
+		args := append([]string{"-C", c.dir, "diff", "--relative"}, set.args...)
+		args = append(args, path)
+		out, err := runCmd("git", args...)
+		if err != nil {
+			return "", err
+		}
+		if out != "" {
+			sb.WriteString(out)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String(), nil
+}
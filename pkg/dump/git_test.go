@@ -0,0 +1,152 @@
+package dump
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initGitRepo creates a repository with one commit containing files.
+func initGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.Name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := runCmd("git", args...); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	git("init", "-q")
+	writeFiles(t, dir, files)
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	git("tag", "base")
+	return dir
+}
+
+func TestGitChangeSets(t *testing.T) {
+	sets, err := gitChangeSets("", false, false)
+	if err != nil || sets != nil {
+		t.Errorf("Expected no change sets without flags, got %v, %v", sets, err)
+	}
+
+	sets, err = gitChangeSets("main", true, true)
+	if err != nil {
+		t.Fatalf("gitChangeSets: %v", err)
+	}
+	if len(sets) != 3 {
+		t.Errorf("Expected 3 change sets, got %v", sets)
+	}
+
+	if _, err := gitChangeSets("--output=/tmp/x", false, false); err == nil {
+		t.Error("Expected error for a ref that looks like an option")
+	}
+}
+
+func TestLoadGitChanges(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{
+		"a.go":     "package a\n",
+		"b.go":     "package b\n",
+		"sub/c.go": "package c\n",
+		"gone.go":  "package gone\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nvar X = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "c.go"), []byte("package c2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd("git", "-C", dir, "add", "sub/c.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ignored.go"), []byte("package a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Unstaged", func(t *testing.T) {
+		sets, _ := gitChangeSets("", false, true)
+		changes, err := loadGitChanges(dir, sets, true)
+		if err != nil {
+			t.Fatalf("loadGitChanges: %v", err)
+		}
+		if !changes.contains("a.go") || changes.contains("b.go") || changes.contains(filepath.Join("sub", "c.go")) {
+			t.Errorf("Unexpected unstaged paths: %v", changes.paths)
+		}
+		if changes.contains("gone.go") {
+			t.Error("Deleted files should not be reported")
+		}
+		if !changes.contains("new.go") || changes.contains("ignored.go") {
+			t.Errorf("Expected the untracked new.go but not the ignored ignored.go, got %v", changes.paths)
+		}
+		diff, err := changes.fileDiff("new.go")
+		if err != nil {
+			t.Fatalf("fileDiff: %v", err)
+		}
+		if expected := "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package a\n"; diff != expected {
+			t.Errorf("untracked diff = %q, expected %q", diff, expected)
+		}
+	})
+
+	t.Run("Staged", func(t *testing.T) {
+		sets, _ := gitChangeSets("", true, false)
+		changes, err := loadGitChanges(dir, sets, false)
+		if err != nil {
+			t.Fatalf("loadGitChanges: %v", err)
+		}
+		if !changes.contains(filepath.Join("sub", "c.go")) || changes.contains("a.go") || changes.contains("new.go") {
+			t.Errorf("Unexpected staged paths: %v", changes.paths)
+		}
+	})
+
+	t.Run("Changed since ref with diff", func(t *testing.T) {
+		sets, _ := gitChangeSets("base", false, false)
+		changes, err := loadGitChanges(dir, sets, true)
+		if err != nil {
+			t.Fatalf("loadGitChanges: %v", err)
+		}
+		if !changes.contains("a.go") || !changes.contains(filepath.Join("sub", "c.go")) {
+			t.Errorf("Unexpected changed paths: %v", changes.paths)
+		}
+		diff, err := changes.fileDiff("a.go")
+		if err != nil {
+			t.Fatalf("fileDiff: %v", err)
+		}
+		if !strings.Contains(diff, "+var X = 1\n") {
+			t.Errorf("Expected diff to contain added line, got %q", diff)
+		}
+	})
+
+	t.Run("Subdirectory paths are relative", func(t *testing.T) {
+		sets, _ := gitChangeSets("", true, false)
+		changes, err := loadGitChanges(filepath.Join(dir, "sub"), sets, false)
+		if err != nil {
+			t.Fatalf("loadGitChanges: %v", err)
+		}
+		if !changes.contains("c.go") {
+			t.Errorf("Expected c.go relative to sub/, got %v", changes.paths)
+		}
+	})
+
+	t.Run("Not a repository", func(t *testing.T) {
+		sets, _ := gitChangeSets("", false, true)
+		_, err := loadGitChanges(t.TempDir(), sets, false)
+		if err == nil || !strings.Contains(err.Error(), "not a git repository") {
+			t.Errorf("Expected not a git repository error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package dump
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileNames are the per-directory ignore files, in increasing precedence.
+// .dumpignore uses the .gitignore syntax for dump-specific exclusions.
+var ignoreFileNames = []string{".gitignore", ".dumpignore"}
+
+// alwaysIgnored are never dumped, wherever they appear.
+var alwaysIgnored = []string{".git", ".gitignore", ".dumpignore"}
+
+// ignorePattern is one line of a gitignore-style file.
+type ignorePattern struct {
+	base    string   // slash path of the directory the pattern is relative to ("" = root)
+	segs    []string // pattern split on "/"; unanchored patterns start with "**"
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreLine parses a gitignore line relative to base, reporting false for
+// blank lines and comments.
+func parseIgnoreLine(line, base string) (ignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	p := ignorePattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// a slash at the start or in the middle anchors the pattern to base;
+	// otherwise it matches at any depth below base
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	p.segs = strings.Split(line, "/")
+	for i, seg := range p.segs {
+		// gitignore negates bracket expressions with "!", path.Match with "^"
+		p.segs[i] = strings.ReplaceAll(seg, "[!", "[^")
+	}
+	if !anchored {
+		p.segs = append([]string{"**"}, p.segs...)
+	}
+	return p, true
+}
+
+// matches reports whether the slash path rel (relative to the repository root) matches.
+func (p *ignorePattern) matches(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		rel = rel[len(p.base)+1:]
+	}
+	return matchSegments(p.segs, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments (at least one when it ends the pattern).
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return len(segs) > 0
+			}
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// readIgnoreFile parses the ignore file at name, returning nothing if it cannot be read.
+func readIgnoreFile(name, base string) []ignorePattern {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// ignoreMatcher applies git's ignore rules to a directory walk: core.excludesFile,
+// .git/info/exclude, then .gitignore and .dumpignore files from the repository root
+// down, then the -i patterns. The last matching pattern decides.
+type ignoreMatcher struct {
+	root   string // repository root, or the walked directory outside a repository
+	prefix string // slash path of the walked directory relative to root ("" = root)
+
+	global []ignorePattern            // core.excludesFile and .git/info/exclude
+	dirs   map[string][]ignorePattern // per-directory patterns by slash path relative to root
+	extra  []ignorePattern            // -i patterns and always-ignored names
+
+	// linguist attributes from .git/info/attributes and .gitattributes files
+	globalAttrs []attrPattern
+	dirAttrs    map[string][]attrPattern
+}
+
+// buildIgnoreList prepares the ignore rules for walking baseDir. extraPatterns use
+// the gitignore syntax relative to baseDir and take precedence over ignore files.
+func buildIgnoreList(baseDir string, extraPatterns []string) (*ignoreMatcher, error) {
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	m := &ignoreMatcher{
+		root:     baseDir,
+		dirs:     make(map[string][]ignorePattern),
+		dirAttrs: make(map[string][]attrPattern),
+	}
+
+	if root, gitDir := findGitRoot(baseDir); root != "" {
+		m.root = root
+		rel, err := filepath.Rel(root, baseDir)
+		if err != nil {
+			return nil, err
+		}
+		if rel != "." {
+			m.prefix = filepath.ToSlash(rel)
+		}
+		if excludesFile := globalExcludesFile(baseDir); excludesFile != "" {
+			m.global = append(m.global, readIgnoreFile(excludesFile, "")...)
+		}
+		m.global = append(m.global, readIgnoreFile(filepath.Join(gitDir, "info", "exclude"), "")...)
+		m.globalAttrs = readAttributesFile(filepath.Join(gitDir, "info", "attributes"), "")
+	}
+
+	// ignore files from the repository root down to baseDir apply to the whole walk
+	dir := ""
+	m.loadDir(dir)
+	if m.prefix != "" {
+		for _, part := range strings.Split(m.prefix, "/") {
+			dir = path.Join(dir, part)
+			m.loadDir(dir)
+		}
+	}
+
+	for _, line := range extraPatterns {
+		if p, ok := parseIgnoreLine(line, m.prefix); ok {
+			m.extra = append(m.extra, p)
+		}
+	}
+	for _, name := range alwaysIgnored {
+		p, _ := parseIgnoreLine(name, "")
+		m.extra = append(m.extra, p)
+	}
+	return m, nil
+}
+
+// findGitRoot returns the work tree containing dir and its git directory, or ""
+// if dir is not inside a repository.
+func findGitRoot(dir string) (root, gitDir string) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return dir, dotGit
+			}
+			// worktrees and submodules point at their git directory from a file
+			if data, err := os.ReadFile(dotGit); err == nil {
+				target := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dir, target)
+				}
+				return dir, target
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// globalExcludesFile returns core.excludesFile, or git's default of
+// $XDG_CONFIG_HOME/git/ignore when it is unset.
+func globalExcludesFile(dir string) string {
+	if _, err := exec.LookPath("git"); err == nil {
+		if out, err := runCmd("git", "-C", dir, "config", "--path", "--get", "core.excludesFile"); err == nil && out != "" {
+			return out
+		}
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore")
+}
+
+// loadDir reads the ignore and attribute files of dir, a slash path relative to root.
+func (m *ignoreMatcher) loadDir(dir string) {
+	if _, ok := m.dirs[dir]; ok {
+		return
+	}
+	var patterns []ignorePattern
+	for _, name := range ignoreFileNames {
+		patterns = append(patterns, readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(dir), name), dir)...)
+	}
+	m.dirs[dir] = patterns
+	m.loadAttributes(dir)
+}
+
+// enterDir loads the ignore files of a directory the walk is about to descend
+// into; relPath is relative to the walked directory.
+func (m *ignoreMatcher) enterDir(relPath string) {
+	m.loadDir(m.rel(relPath))
+}
+
+// rel converts a path relative to the walked directory to a slash path relative to root.
+func (m *ignoreMatcher) rel(relPath string) string {
+	rel := filepath.ToSlash(relPath)
+	if rel == "." {
+		rel = ""
+	}
+	return path.Join(m.prefix, rel)
+}
+
+// ignored reports whether relPath (relative to the walked directory) is ignored.
+// The walk skips ignored directories, so parents are not checked.
+func (m *ignoreMatcher) ignored(relPath string, isDir bool) bool {
+	rel := m.rel(relPath)
+	ignored := false
+	check := func(patterns []ignorePattern) {
+		for i := range patterns {
+			if patterns[i].matches(rel, isDir) {
+				ignored = !patterns[i].negate
+			}
+		}
+	}
+
+	check(m.global)
+	check(m.dirs[""])
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			check(m.dirs[rel[:i]])
+		}
+	}
+	check(m.extra)
+	return ignored
+}
+
+// excluded reports whether relPath, taken as a file, or any of its parent
+// directories matches the -i patterns (or an always-ignored name). Unlike
+// MatchesPath, the ignore files don't apply.
+func (m *ignoreMatcher) excluded(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := 1; i <= len(parts); i++ {
+		rel, isDir := m.rel(strings.Join(parts[:i], "/")), i < len(parts)
+		ignored := false
+		for j := range m.extra {
+			if m.extra[j].matches(rel, isDir) {
+				ignored = !m.extra[j].negate
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPath reports whether relPath, taken as a file, or any of its parent
+// directories is ignored. Ignore files are loaded for the parents as needed.
+func (m *ignoreMatcher) MatchesPath(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if m.ignored(dir, true) {
+			return true
+		}
+		m.enterDir(dir)
+	}
+	return m.ignored(relPath, false)
+}
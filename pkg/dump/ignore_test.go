@@ -0,0 +1,143 @@
+package dump
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIgnorePatternMatches(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		base     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"*.log", "", "debug.log", false, true},
+		{"*.log", "", "a/b/debug.log", false, true},
+		{"*.log", "sub", "debug.log", false, false},
+		{"*.log", "sub", "sub/x/debug.log", false, true},
+		{"/build", "", "build", true, true},
+		{"/build", "", "src/build", true, false},
+		{"build/", "", "src/build", true, true},
+		{"build/", "", "src/build", false, false},
+		{"doc/*.txt", "", "doc/a.txt", false, true},
+		{"doc/*.txt", "", "doc/x/a.txt", false, false},
+		{"doc/*.txt", "", "x/doc/a.txt", false, false},
+		{"**/foo", "", "a/b/foo", false, true},
+		{"**/foo/bar", "", "foo/bar", false, true},
+		{"abc/**", "", "abc/x/y", false, true},
+		{"abc/**", "", "abc", true, false},
+		{"a/**/b", "", "a/b", false, true},
+		{"a/**/b", "", "a/x/y/b", false, true},
+		{"[!a]*.go", "", "b.go", false, true},
+		{"[!a]*.go", "", "a.go", false, false},
+		{`\#notes`, "", "#notes", false, true},
+		{`\!important`, "", "!important", false, true},
+		{"trailing.txt   ", "", "trailing.txt", false, true},
+		{`space\ `, "", "space ", false, true},
+	}
+	for _, tc := range testCases {
+		p, ok := parseIgnoreLine(tc.pattern, tc.base)
+		if !ok {
+			t.Errorf("parseIgnoreLine(%q) rejected the pattern", tc.pattern)
+			continue
+		}
+		if got := p.matches(tc.path, tc.isDir); got != tc.expected {
+			t.Errorf("%q (base %q) matches %q (dir: %v) = %v, expected %v", tc.pattern, tc.base, tc.path, tc.isDir, got, tc.expected)
+		}
+	}
+
+	for _, line := range []string{"", "# comment", "   ", "/", "!"} {
+		if _, ok := parseIgnoreLine(line, ""); ok {
+			t.Errorf("parseIgnoreLine(%q) should be skipped", line)
+		}
+	}
+}
+
+func TestDirectoryIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	// keep the user's git config out of the test
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(root, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "xdg"))
+
+	repo := filepath.Join(root, "repo")
+	files := map[string]string{
+		".git/info/exclude":      "*.tmp\n",
+		".gitignore":             "*.gen\n/top.txt\nbuild/\n",
+		".dumpignore":            "secrets/\n",
+		"main.go":                "package main\n",
+		"top.txt":                "ignored at the root only\n",
+		"scratch.tmp":            "excluded by info/exclude\n",
+		"global.bak":             "excluded by core.excludesFile\n",
+		"a.gen":                  "generated\n",
+		"secrets/key.txt":        "secret\n",
+		"build/out.txt":          "artifact\n",
+		"pkg/top.txt":            "not anchored here\n",
+		"pkg/.gitignore":         "!keep.gen\n/local.txt\nout/\n",
+		"pkg/keep.gen":           "re-included\n",
+		"pkg/drop.gen":           "generated\n",
+		"pkg/local.txt":          "ignored by pkg/.gitignore\n",
+		"pkg/sub/local.txt":      "anchored to pkg, so kept\n",
+		"pkg/out/x.txt":          "nested build output\n",
+		"pkg/sub/.dumpignore":    "*.md\n",
+		"pkg/sub/README.md":      "dump-specific exclusion\n",
+		"pkg/sub/deep/notes.txt": "kept\n",
+	}
+	writeFiles(t, repo, files)
+	excludes := filepath.Join(root, "xdg", "git", "ignore")
+	if err := os.MkdirAll(filepath.Dir(excludes), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(excludes, []byte("*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(dir string, extra ...string) []string {
+		env, err := newEnv(&Options{List: true, Ignore: extra, Stderr: io.Discard})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := (&DirectorySource{Dir: dir}).Collect(context.Background(), env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, b := range blocks {
+			paths = append(paths, filepath.ToSlash(b.Item.Path))
+		}
+		return paths
+	}
+
+	t.Run("Repository root", func(t *testing.T) {
+		expected := []string{
+			"repo/main.go",
+			"repo/pkg/keep.gen",
+			"repo/pkg/sub/deep/notes.txt",
+			"repo/pkg/sub/local.txt",
+			"repo/pkg/top.txt",
+		}
+		if got := list(repo); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Dumped %q, expected %q", got, expected)
+		}
+	})
+
+	t.Run("Subdirectory inherits parent rules", func(t *testing.T) {
+		expected := []string{"pkg/keep.gen", "pkg/sub/deep/notes.txt", "pkg/sub/local.txt", "pkg/top.txt"}
+		if got := list(filepath.Join(repo, "pkg")); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Dumped %q, expected %q", got, expected)
+		}
+	})
+
+	t.Run("Ignore flags take precedence", func(t *testing.T) {
+		expected := []string{"pkg/sub/deep/notes.txt", "pkg/sub/local.txt", "pkg/top.txt"}
+		if got := list(filepath.Join(repo, "pkg"), "*.gen"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Dumped %q, expected %q", got, expected)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// StdinSource wraps everything read from Reader as a single item labelled Label.
+type StdinSource struct {
+	Reader io.Reader
+	Label  string
+}
+
+func (s *StdinSource) Name() string {
+	return "stdin"
+}
+
+func (s *StdinSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	if env.List {
+		return nil, nil
+	}
+	item, err := readStdinItem(s.Reader, s.Label, env.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return []*Block{{Item: item}}, nil
+}
+
+// FileListSource dumps exactly the files named in List, a newline or NUL separated
+// file; "-" reads the list from Stdin.
+type FileListSource struct {
+	List  string
+	Stdin io.Reader
+}
+
+func (s *FileListSource) Name() string {
+	return "files from " + s.List
+}
+
+func (s *FileListSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	r := s.Stdin
+	label := "stdin"
+	if s.List != "-" {
+		f, err := os.Open(s.List)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --files-from: %w", err)
+		}
+		defer f.Close()
+		r = f
+		label = s.List
+	}
+	paths, err := readFileList(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --files-from: %w", err)
+	}
+
+	var items []*Item
+	var listTree *TreeNode
+	if env.Tree {
+		listTree = &TreeNode{Name: ".", Path: label, IsDir: true}
+	}
+	processFileList(paths, env, &items, listTree)
+	if err := sortItems(items, env.opts.Sort, "."); err != nil {
+		return nil, err
+	}
+
+	var blocks []*Block
+	if listTree != nil {
+		blocks = append(blocks, &Block{Tree: listTree})
+	}
+	for _, item := range items {
+		blocks = append(blocks, &Block{Item: item})
+	}
+	return blocks, nil
+}
+
+// readStdinItem wraps everything read from r as a single item labelled label.
+func readStdinItem(r io.Reader, label string, filter *regexp.Regexp) (*Item, error) {
+	if filter != nil {
+		content, err := filterContent(r, filter)
+		if err != nil {
+			return nil, err
+		}
+		return &Item{Path: label, Content: content}, nil
+	}
+	cb, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Item{Path: label, Content: string(cb)}, nil
+}
+
+// readFileList parses a list of paths separated by NULs (as from `git ls-files -z`
+// or `fd -0`) or, if there are none, by newlines.
+func readFileList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sep := []byte("\n")
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = []byte{0}
+	}
+	var paths []string
+	for _, p := range bytes.Split(data, sep) {
+		path := strings.TrimRight(string(p), "\r")
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// processFileList dumps exactly the listed files, applying the same glob/extension
+// filters, -i patterns, size limit, generated-file rules, text detection, line
+// filter and --contains as a directory walk.
+func processFileList(paths []string, env *Env, items *[]*Item, treeRoot *TreeNode) {
+	// .gitattributes apply to the listed files below the current directory, and
+	// -i patterns are relative to it; ignore files don't apply to a list
+	ignores, _ := buildIgnoreList(".", env.opts.Ignore)
+
+	var kept []string
+	for _, path := range paths {
+		displayPath := filepath.Clean(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "skipping %s: %v\n", path, err)
+			continue
+		}
+		if info.IsDir() {
+			fmt.Fprintf(env.Stderr, "skipping %s: is a directory\n", path)
+			continue
+		}
+		if !matchesFileFilters(displayPath, env.globs, env.extSet) {
+			continue
+		}
+		fileAttrs, relPath := ignores, displayPath
+		if filepath.IsAbs(displayPath) || displayPath == ".." || strings.HasPrefix(displayPath, ".."+string(filepath.Separator)) {
+			// outside the current directory, only patterns without a slash can match
+			fileAttrs, relPath = nil, filepath.Base(displayPath)
+		}
+		if ignores != nil && ignores.excluded(relPath) {
+			continue
+		}
+		if reason := env.skipReason(path, filepath.ToSlash(displayPath), info, fileAttrs); reason != "" {
+			env.skip(displayPath, reason)
+			continue
+		}
+
+		output := env.collectFile(path, displayPath)
+		if output == nil {
+			continue
+		}
+		kept = append(kept, displayPath)
+		*items = append(*items, output)
+	}
+
+	if treeRoot != nil {
+		addPathsToTree(treeRoot, kept)
+	}
+}
+
+// addPathsToTree adds a node for every component of each path beneath root.
+func addPathsToTree(root *TreeNode, paths []string) {
+	for _, p := range paths {
+		node := root
+		nodePath := ""
+		parts := strings.Split(filepath.ToSlash(p), "/")
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			nodePath = filepath.Join(nodePath, part)
+			isDir := i < len(parts)-1
+
+			var child *TreeNode
+			for _, c := range node.Children {
+				if c.Name == part && c.IsDir == isDir {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &TreeNode{Name: part, Path: nodePath, IsDir: isDir}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package dump
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReadStdinItem(t *testing.T) {
+	item, err := readStdinItem(strings.NewReader("ok 1\nFAIL 2\nok 3\n"), "go test", regexp.MustCompile(`^FAIL`))
+	if err != nil {
+		t.Fatalf("readStdinItem: %v", err)
+	}
+	if item.Path != "go test" {
+		t.Errorf("Expected label %q, got %q", "go test", item.Path)
+	}
+	if item.Content != "ok 1\nok 3\n" {
+		t.Errorf("Expected filtered content, got %q", item.Content)
+	}
+
+	item, err = readStdinItem(strings.NewReader("no newline"), "stdin", nil)
+	if err != nil {
+		t.Fatalf("readStdinItem: %v", err)
+	}
+	if item.Content != "no newline" {
+		t.Errorf("Expected raw content without a filter, got %q", item.Content)
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"Newline separated", "a.go\nsub/b.go\n\n", []string{"a.go", "sub/b.go"}},
+		{"CRLF separated", "a.go\r\nb.go\r\n", []string{"a.go", "b.go"}},
+		{"NUL separated", "a.go\x00name with\nnewline.txt\x00", []string{"a.go", "name with\nnewline.txt"}},
+		{"Empty", "", nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readFileList(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("readFileList: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("readFileList(%q) = %q, expected %q", tc.input, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProcessFileList(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":         "package main\n// comment\n",
+		"sub/helper.go":   "package sub\n",
+		"notes.md":        "# notes\n",
+		"image.bin":       "\x00\x01\x02",
+		"sub/nested/x.go": "package nested\n",
+	}
+	writeFiles(t, dir, files)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	paths := []string{"main.go", "sub/helper.go", "notes.md", "image.bin", "missing.go", "sub"}
+	env, err := newEnv(&Options{Exts: []string{"go", "bin"}, Filter: `^//`, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*Item
+	root := &TreeNode{Name: ".", Path: "list", IsDir: true}
+	processFileList(paths, env, &items, root)
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].Path != "main.go" || items[0].Content != "package main\n" {
+		t.Errorf("Unexpected first item: %+v", *items[0])
+	}
+	if items[1].Path != filepath.Join("sub", "helper.go") {
+		t.Errorf("Unexpected second item path: %q", items[1].Path)
+	}
+
+	tree := formatTreeNode(root, "", true)
+	expected := "├── main.go\n└── sub\n    └── helper.go\n"
+	if tree != expected {
+		t.Errorf("Tree = %q, expected %q", tree, expected)
+	}
+}
+
+func TestProcessFileListIgnore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.go":       "package main\n",
+		"sub/helper.go": "package sub\n",
+		"notes.md":      "# notes\n",
+		"other.go":      "package main\n",
+		".gitignore":    "main.go\n",
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// -i applies to listed files, ignore files don't
+	paths := []string{"main.go", "sub/helper.go", "notes.md", filepath.Join(dir, "notes.md"), "other.go"}
+	env, err := newEnv(&Options{Ignore: []string{"sub/", "*.md", "/other.go"}, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*Item
+	processFileList(paths, env, &items, nil)
+
+	if len(items) != 1 || items[0].Path != "main.go" {
+		t.Errorf("items = %+v, expected only main.go", items)
+	}
+}
@@ -0,0 +1,214 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// jsonRecord is a single dumped source in json and jsonl output.
+type jsonRecord struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path,omitempty"`
+	URL     string `json:"url,omitempty"`
+	PaneID  string `json:"pane_id,omitempty"`
+	Session string `json:"session,omitempty"`
+	Window  string `json:"window,omitempty"`
+	Pane    string `json:"pane,omitempty"`
+	Command string `json:"command,omitempty"`
+	// ExitCode and DurationMS are only set for commands
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMS *int64 `json:"duration_ms,omitempty"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Size       int    `json:"size"`
+	Lines      int    `json:"lines"`
+	Content    string `json:"content"`
+	// LineNumbers is set when each content line starts with its line number
+	LineNumbers bool `json:"line_numbers,omitempty"`
+	// Binary is hex, base64 or placeholder for a binary file (--include-binary)
+	Binary string `json:"binary,omitempty"`
+	// Transforms names the steps that changed the content from the file
+	Transforms []string `json:"transforms,omitempty"`
+	// Meta holds the file's --meta attributes
+	Meta   *FileMeta `json:"meta,omitempty"`
+	Diff   string    `json:"diff,omitempty"`
+	Stderr string    `json:"stderr,omitempty"`
+	// Paths lists the files removed since the snapshot (--since-snapshot)
+	Paths []string `json:"paths,omitempty"`
+}
+
+// jsonMetadata summarizes a json document.
+type jsonMetadata struct {
+	Version  string `json:"version"`
+	Files    int    `json:"files"`
+	URLs     int    `json:"urls"`
+	Tmux     int    `json:"tmux"`
+	Commands int    `json:"commands"`
+	Trees    int    `json:"trees"`
+	Removed  int    `json:"removed,omitempty"`
+	Size     int    `json:"size"`
+	// Part and Parts number the document when the dump is split (--split-tokens)
+	Part  int `json:"part,omitempty"`
+	Parts int `json:"parts,omitempty"`
+}
+
+// jsonDocument is the top-level object emitted by -o json.
+type jsonDocument struct {
+	Files    []jsonRecord `json:"files"`
+	URLs     []jsonRecord `json:"urls"`
+	Tmux     []jsonRecord `json:"tmux"`
+	Commands []jsonRecord `json:"commands"`
+	Tree     []jsonRecord `json:"tree"`
+	// Removed lists the files removed since the snapshot (--since-snapshot)
+	Removed  []string     `json:"removed,omitempty"`
+	Metadata jsonMetadata `json:"metadata"`
+}
+
+// countLines returns the number of lines in s, counting a final unterminated line.
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+func newJSONRecord(b *Block) jsonRecord {
+	rec := jsonRecord{Kind: b.Kind()}
+	switch rec.Kind {
+	case "tree":
+		rec.Path = b.Tree.Path
+		rec.Content = formatTreeNode(b.Tree, "", true)
+	case "tmux":
+		rec.PaneID = b.Tmux.ID
+		rec.Session = b.Tmux.Session
+		rec.Window = b.Tmux.Window
+		rec.Pane = b.Tmux.Pane
+		rec.Content = b.Tmux.Content
+	case "command":
+		exitCode := b.Command.ExitCode
+		durationMS := b.Command.Duration.Milliseconds()
+		rec.Command = b.Command.Command
+		rec.ExitCode = &exitCode
+		rec.DurationMS = &durationMS
+		rec.TimedOut = b.Command.TimedOut
+		rec.Content = b.Command.Stdout
+		rec.Stderr = b.Command.Stderr
+	case "removed":
+		rec.Paths = b.Removed.Paths
+	case "url":
+		rec.URL = b.Item.Path
+		rec.Content = b.Item.Content
+	default:
+		rec.Path = b.Item.Path
+		rec.Content = b.Item.Content
+		rec.Diff = b.Item.Diff
+		rec.LineNumbers = b.Item.LineNumbers
+		rec.Binary = b.Item.Binary
+		rec.Transforms = b.Item.Transforms
+		rec.Meta = b.Item.Meta
+	}
+	rec.Size = len(rec.Content)
+	rec.Lines = countLines(rec.Content)
+	return rec
+}
+
+// marshalJSON encodes v without HTML escaping so code stays readable.
+func marshalJSON(v any, indent string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	// records only hold strings and ints, so encoding cannot fail
+	_ = enc.Encode(v)
+	return buf.String()
+}
+
+// formatJSONRecord renders a block as a single jsonl line.
+func formatJSONRecord(b *Block) string {
+	return marshalJSON(newJSONRecord(b), "")
+}
+
+// formatJSONDocumentRecord renders a block's record as formatJSONDocument
+// writes it, followed by a comma; a removed list is one line per path.
+func formatJSONDocumentRecord(b *Block) string {
+	if b.Removed != nil {
+		var sb strings.Builder
+		for _, p := range b.Removed.Paths {
+			sb.WriteString("    " + strings.TrimSuffix(marshalJSON(p, ""), "\n") + ",\n")
+		}
+		return sb.String()
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("    ", "  ")
+	_ = enc.Encode(newJSONRecord(b))
+	return "    " + strings.TrimSuffix(buf.String(), "\n") + ",\n"
+}
+
+// formatJSONFrame renders the json document for blocks without their records.
+// The metadata counts every block, so it is at least as long as the metadata
+// of any subset that the token budget keeps.
+func formatJSONFrame(blocks []*Block, version string) string {
+	doc := newJSONDocument(blocks, version)
+	removed := len(doc.Removed) > 0
+	arrays := []struct {
+		key  string
+		recs []jsonRecord
+	}{{"files", doc.Files}, {"urls", doc.URLs}, {"tmux", doc.Tmux}, {"commands", doc.Commands}, {"tree", doc.Tree}}
+	doc.Files, doc.URLs, doc.Tmux, doc.Commands, doc.Tree = []jsonRecord{}, []jsonRecord{}, []jsonRecord{}, []jsonRecord{}, []jsonRecord{}
+	doc.Removed = nil
+	frame := marshalJSON(doc, "  ")
+	// an array with records spans lines of its own
+	for _, a := range arrays {
+		if len(a.recs) > 0 {
+			frame = strings.Replace(frame, `"`+a.key+`": []`, `"`+a.key+`": [`+"\n  ]", 1)
+		}
+	}
+	if removed {
+		frame = strings.Replace(frame, "\n  \"metadata\"", "\n  \"removed\": [\n  ],\n  \"metadata\"", 1)
+	}
+	return frame
+}
+
+// formatJSONDocument renders all blocks as one json document grouped by kind.
+func formatJSONDocument(blocks []*Block, version string) string {
+	return marshalJSON(newJSONDocument(blocks, version), "  ")
+}
+
+func newJSONDocument(blocks []*Block, version string) jsonDocument {
+	doc := jsonDocument{
+		Files:    []jsonRecord{},
+		URLs:     []jsonRecord{},
+		Tmux:     []jsonRecord{},
+		Commands: []jsonRecord{},
+		Tree:     []jsonRecord{},
+	}
+	for _, b := range blocks {
+		rec := newJSONRecord(b)
+		switch rec.Kind {
+		case "tree":
+			doc.Tree = append(doc.Tree, rec)
+		case "tmux":
+			doc.Tmux = append(doc.Tmux, rec)
+		case "command":
+			doc.Commands = append(doc.Commands, rec)
+		case "removed":
+			doc.Removed = append(doc.Removed, rec.Paths...)
+		case "url":
+			doc.URLs = append(doc.URLs, rec)
+		default:
+			doc.Files = append(doc.Files, rec)
+		}
+		doc.Metadata.Size += rec.Size
+	}
+	doc.Metadata.Version = version
+	doc.Metadata.Files = len(doc.Files)
+	doc.Metadata.URLs = len(doc.URLs)
+	doc.Metadata.Tmux = len(doc.Tmux)
+	doc.Metadata.Commands = len(doc.Commands)
+	doc.Metadata.Trees = len(doc.Tree)
+	doc.Metadata.Removed = len(doc.Removed)
+	return doc
+}
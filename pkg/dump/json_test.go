@@ -0,0 +1,148 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCountLines(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"one\n", 1},
+		{"one\ntwo", 2},
+		{"one\ntwo\n", 2},
+		{"\n\n", 2},
+	}
+	for _, tc := range testCases {
+		if got := countLines(tc.content); got != tc.expected {
+			t.Errorf("countLines(%q) = %d, expected %d", tc.content, got, tc.expected)
+		}
+	}
+}
+
+func TestFormatJSONRecord(t *testing.T) {
+	testCases := []struct {
+		name     string
+		block    *Block
+		expected string
+	}{
+		{
+			name:     "File",
+			block:    &Block{Item: &Item{Path: "src/main.go", Content: "package main\n<tag> & done\n"}},
+			expected: `{"kind":"file","path":"src/main.go","size":26,"lines":2,"content":"package main\n<tag> & done\n"}` + "\n",
+		},
+		{
+			name:     "URL",
+			block:    &Block{Item: &Item{Path: "https://example.com", Content: "web content\n"}},
+			expected: `{"kind":"url","url":"https://example.com","size":12,"lines":1,"content":"web content\n"}` + "\n",
+		},
+		{
+			name:     "Tmux pane",
+			block:    &Block{Tmux: &TmuxPaneItem{ID: "%1", Session: "s", Window: "0", Pane: "1", Content: "echo hi\n"}},
+			expected: `{"kind":"tmux","pane_id":"%1","session":"s","window":"0","pane":"1","size":8,"lines":1,"content":"echo hi\n"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := (textRenderer{format: "jsonl"}).RenderBlock(tc.block); got != tc.expected {
+				t.Errorf("render(jsonl) = %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFormatJSONDocument(t *testing.T) {
+	tree := &TreeNode{Name: "proj", Path: "/tmp/proj", IsDir: true}
+	tree.Children = []*TreeNode{{Name: "main.go", Path: "/tmp/proj/main.go"}}
+	blocks := []*Block{
+		{Tree: tree},
+		{Item: &Item{Path: "proj/main.go", Content: "package main\n"}},
+		{Item: &Item{Path: "https://example.com", Content: "web\n"}},
+		{Tmux: &TmuxPaneItem{ID: "%2", Content: "pane\n"}},
+	}
+
+	out := formatJSONDocument(blocks, "dev")
+	var doc jsonDocument
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("formatJSONDocument produced invalid JSON: %v\n%s", err, out)
+	}
+
+	if len(doc.Files) != 1 || doc.Files[0].Path != "proj/main.go" {
+		t.Errorf("Expected one file record for proj/main.go, got %+v", doc.Files)
+	}
+	if len(doc.URLs) != 1 || doc.URLs[0].URL != "https://example.com" {
+		t.Errorf("Expected one url record, got %+v", doc.URLs)
+	}
+	if len(doc.Tmux) != 1 || doc.Tmux[0].PaneID != "%2" {
+		t.Errorf("Expected one tmux record, got %+v", doc.Tmux)
+	}
+	if len(doc.Tree) != 1 || !strings.Contains(doc.Tree[0].Content, "main.go") {
+		t.Errorf("Expected one tree record listing main.go, got %+v", doc.Tree)
+	}
+	if doc.Metadata.Files != 1 || doc.Metadata.URLs != 1 || doc.Metadata.Tmux != 1 || doc.Metadata.Trees != 1 {
+		t.Errorf("Unexpected metadata counts: %+v", doc.Metadata)
+	}
+
+	empty := formatJSONDocument(nil, "dev")
+	if !strings.Contains(empty, `"files": []`) {
+		t.Errorf("Expected empty arrays rather than null, got %s", empty)
+	}
+}
+
+// signalWriter closes written on its first write.
+type signalWriter struct {
+	buf     bytes.Buffer
+	once    sync.Once
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.written) })
+	return w.buf.Write(p)
+}
+
+// gatedSource only finishes once its gate closes.
+type gatedSource struct {
+	gate <-chan struct{}
+}
+
+func (s gatedSource) Name() string { return "gated" }
+
+func (s gatedSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	select {
+	case <-s.gate:
+		return []*Block{{Item: &Item{Path: "second", Content: "2\n"}}}, nil
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("the first record was not written before the last source finished")
+	}
+}
+
+func TestRunJSONLStreams(t *testing.T) {
+	w := &signalWriter{written: make(chan struct{})}
+	opts := DefaultOptions()
+	opts.Format = "jsonl"
+	opts.Sources = []Source{
+		&staticSource{items: []*Item{{Path: "first", Content: "1\n"}}},
+		gatedSource{gate: w.written},
+	}
+	var stderr bytes.Buffer
+	opts.Stderr = &stderr
+	if err := Run(context.Background(), opts, w); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	out := w.buf.String()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"path":"first"`) || !strings.Contains(lines[1], `"path":"second"`) {
+		t.Errorf("Run output = %q, expected the first and second records (stderr %q)", out, stderr.String())
+	}
+}
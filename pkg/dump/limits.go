@@ -0,0 +1,170 @@
+package dump
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sizeUnits are the suffixes ParseSize accepts, as powers of 1024.
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GIB", 1 << 30}, {"GB", 1 << 30}, {"G", 1 << 30},
+	{"MIB", 1 << 20}, {"MB", 1 << 20}, {"M", 1 << 20},
+	{"KIB", 1 << 10}, {"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as 4096, 512K, 2MB or 1GiB. The K, M and G
+// suffixes are powers of 1024 and are case insensitive.
+func ParseSize(s string) (int64, error) {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected a number of bytes with an optional K, M or G suffix)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// formatSize formats a byte count for reports, like 512 B or 40.0 MiB.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// formatCount formats n with comma thousands separators, like 12,345.
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// truncationMarker stands in for n lines cut from a file.
+func truncationMarker(n int) contentLine {
+	return contentLine{text: fmt.Sprintf("... [truncated %s lines] ...", formatCount(n))}
+}
+
+// lineLimits are the --max-lines, --head and --tail settings.
+type lineLimits struct {
+	maxLines   int
+	head, tail int
+}
+
+// apply cuts lines down to the limits, replacing what it removes with a marker.
+// Without maxLines, head and tail apply to every file; with it, only files longer
+// than maxLines are cut, to their head and tail lines if set and to their first
+// maxLines lines otherwise.
+func (l lineLimits) apply(lines []contentLine) []contentLine {
+	head, tail := l.head, l.tail
+	if l.maxLines > 0 {
+		if len(lines) <= l.maxLines {
+			return lines
+		}
+		if head == 0 && tail == 0 {
+			head = l.maxLines
+		}
+	}
+	if (head == 0 && tail == 0) || len(lines) <= head+tail {
+		return lines
+	}
+	cut := len(lines) - head - tail
+	kept := make([]contentLine, 0, head+tail+1)
+	kept = append(kept, lines[:head]...)
+	kept = append(kept, truncationMarker(cut))
+	return append(kept, lines[len(lines)-tail:]...)
+}
+
+// skippedFile is a file left out of the dump and why.
+type skippedFile struct {
+	path   string
+	reason string
+}
+
+// skipList collects the files sources leave out; sources add to it concurrently.
+type skipList struct {
+	mu    sync.Mutex
+	files []skippedFile
+}
+
+func (s *skipList) add(path, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, skippedFile{path: path, reason: reason})
+}
+
+// sorted returns the skipped files ordered by path.
+func (s *skipList) sorted() []skippedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := append([]skippedFile(nil), s.files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files
+}
+
+// skipReason reports why the file at path should be left out of the dump, or ""
+// to keep it. relPath is its slash path within the walk, for the generated-file
+// rules, and attrs holds the walk's .gitattributes rules (nil for none).
+func (e *Env) skipReason(path, relPath string, info fs.FileInfo, attrs *ignoreMatcher) string {
+	if !e.allowed(path) {
+		return "outside the allowed roots"
+	}
+	if limit := e.opts.MaxFileSize; limit > 0 && info.Size() > limit {
+		return fmt.Sprintf("%s exceeds --max-file-size %s", formatSize(info.Size()), formatSize(limit))
+	}
+	enc, sample, err := sniffFile(path)
+	if err != nil {
+		return err.Error()
+	}
+	if !e.opts.IncludeGenerated {
+		if reason := generatedReason(relPath, sample, attrs); reason != "" {
+			return reason
+		}
+	}
+	if enc == encBinary && (e.binary == "" || e.binary == "skip") {
+		return "binary"
+	}
+	return ""
+}
+
+// skip records that the file at displayPath was left out of the dump.
+func (e *Env) skip(displayPath, reason string) {
+	e.skipped.add(displayPath, reason)
+}
+
+func formatSkipReport(files []skippedFile) string {
+	var sb strings.Builder
+	noun := "files"
+	if len(files) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(&sb, "skipped %d %s:\n", len(files), noun)
+	for _, f := range files {
+		fmt.Fprintf(&sb, "  %s: %s\n", f.path, f.reason)
+	}
+	return sb.String()
+}
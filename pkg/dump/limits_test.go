@@ -0,0 +1,111 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"4096", 4096},
+		{"512K", 512 << 10},
+		{"2MB", 2 << 20},
+		{"1.5m", 3 << 19},
+		{"1GiB", 1 << 30},
+		{"100 B", 100},
+	}
+	for _, tc := range testCases {
+		got, err := ParseSize(tc.input)
+		if err != nil {
+			t.Errorf("ParseSize(%q): %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("ParseSize(%q) = %d, expected %d", tc.input, got, tc.expected)
+		}
+	}
+	for _, input := range []string{"", "MB", "-1K", "10X"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("Expected an error for ParseSize(%q)", input)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	testCases := map[int]string{0: "0", 999: "999", 1000: "1,000", 12345: "12,345", 1234567: "1,234,567"}
+	for n, expected := range testCases {
+		if got := formatCount(n); got != expected {
+			t.Errorf("formatCount(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
+
+func TestLineLimits(t *testing.T) {
+	var sb strings.Builder
+	for i := 1; i <= 10; i++ {
+		sb.WriteString(strings.Repeat("x", i) + "\n")
+	}
+	src := sb.String()
+
+	testCases := []struct {
+		name     string
+		limits   lineLimits
+		expected string
+	}{
+		{"No limits", lineLimits{}, src},
+		{"Head", lineLimits{head: 2}, "x\nxx\n... [truncated 8 lines] ...\n"},
+		{"Tail", lineLimits{tail: 1}, "... [truncated 9 lines] ...\nxxxxxxxxxx\n"},
+		{"Head and tail", lineLimits{head: 1, tail: 1}, "x\n... [truncated 8 lines] ...\nxxxxxxxxxx\n"},
+		{"Head and tail cover the file", lineLimits{head: 5, tail: 5}, src},
+		{"Max lines keeps the first lines", lineLimits{maxLines: 3}, "x\nxx\nxxx\n... [truncated 7 lines] ...\n"},
+		{"Max lines with tail", lineLimits{maxLines: 3, tail: 1}, "... [truncated 9 lines] ...\nxxxxxxxxxx\n"},
+		{"Short file under max lines", lineLimits{maxLines: 10, head: 1}, src},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := (&Env{limits: tc.limits}).fileContent("a.txt", src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("fileContent = %q, expected %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRunSkipReport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"big.json":  strings.Repeat("{}\n", 1000),
+		"image.png": "\x89PNG\r\n\x1a\n\x00\x00",
+		"main.go":   "package main\n",
+	}
+	writeFiles(t, dir, files)
+
+	opts := DefaultOptions()
+	opts.Dirs = []string{dir}
+	opts.MaxFileSize = 1 << 10
+	var stdout, stderr bytes.Buffer
+	opts.Stderr = &stderr
+	if err := Run(context.Background(), opts, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(stdout.String(), "big.json") || !strings.Contains(stdout.String(), "main.go") {
+		t.Errorf("Unexpected dump:\n%s", stdout.String())
+	}
+	base := filepath.Base(dir)
+	expected := "skipped 2 files:\n" +
+		"  " + filepath.Join(base, "big.json") + ": 2.9 KiB exceeds --max-file-size 1.0 KiB\n" +
+		"  " + filepath.Join(base, "image.png") + ": binary\n"
+	if stderr.String() != expected {
+		t.Errorf("stderr = %q, expected %q", stderr.String(), expected)
+	}
+}
@@ -0,0 +1,512 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpProtocolVersions are the Model Context Protocol revisions the server speaks,
+// newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpContent is a text item of a tool result.
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError"`
+}
+
+// toolArgs are the arguments of a tool call, decoded from json and applied to
+// the server's base options.
+type toolArgs interface {
+	apply(opts *Options) error
+}
+
+// mcpTool is a tool the server exposes. Its input schema is derived from the
+// fields of its arguments struct.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	newArgs func() toolArgs
+	tree    bool // renders directory trees only (RunTree)
+}
+
+// override sets *dst to *v when the client sent the argument. Arguments are
+// pointers, or nil slices, that stay nil when left out, so only those sent
+// override the base options from the config files and profile.
+func override[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// overrideList sets *dst to v when the list argument was sent.
+func overrideList(dst *[]string, v []string) {
+	if v != nil {
+		*dst = v
+	}
+}
+
+// fileArgs select the files of directories, like the CLI's filter flags.
+type fileArgs struct {
+	Dir              []string `json:"dir,omitempty" desc:"directories to walk (default: the server's working directory)"`
+	Glob             []string `json:"glob,omitempty" desc:"glob patterns to match files, like **.go (OR with ext)"`
+	Ext              []string `json:"ext,omitempty" desc:"file extensions to include, like go or .md"`
+	Ignore           []string `json:"ignore,omitempty" desc:"gitignore-style patterns to exclude"`
+	IncludeGenerated *bool    `json:"include_generated,omitempty" desc:"also include generated, vendored, minified and lock files"`
+	MaxFileSize      *string  `json:"max_file_size,omitempty" desc:"skip files larger than this size, like 512K or 2MB"`
+}
+
+func (a *fileArgs) apply(opts *Options) error {
+	overrideList(&opts.Dirs, a.Dir)
+	overrideList(&opts.Globs, a.Glob)
+	overrideList(&opts.Exts, a.Ext)
+	overrideList(&opts.Ignore, a.Ignore)
+	override(&opts.IncludeGenerated, a.IncludeGenerated)
+	if a.MaxFileSize != nil {
+		n, err := ParseSize(*a.MaxFileSize)
+		if err != nil {
+			return err
+		}
+		opts.MaxFileSize = n
+	}
+	return nil
+}
+
+type listArgs struct {
+	fileArgs
+	Contains    []string `json:"contains,omitempty" desc:"only files with a line matching any of these regexes"`
+	ContainsAll *bool    `json:"contains_all,omitempty" desc:"require every contains regex to match"`
+	Sort        *string  `json:"sort,omitempty" desc:"file order" enum:"path,size,mtime,extension,git-recency"`
+}
+
+func (a *listArgs) apply(opts *Options) error {
+	overrideList(&opts.Contains, a.Contains)
+	override(&opts.ContainsAll, a.ContainsAll)
+	override(&opts.Sort, a.Sort)
+	opts.List = true
+	return a.fileArgs.apply(opts)
+}
+
+type dumpArgs struct {
+	listArgs
+	OutFmt        *string  `json:"out_fmt,omitempty" desc:"output format" enum:"xml,xml-strict,md,json,jsonl"`
+	Filter        *string  `json:"filter,omitempty" desc:"drop lines matching this regex"`
+	Context       *int     `json:"context,omitempty" desc:"show only the lines matching contains and this many lines around each"`
+	Tree          *bool    `json:"tree,omitempty" desc:"add the directory tree"`
+	Outline       *bool    `json:"outline,omitempty" desc:"reduce Go files to declarations and signatures"`
+	StripComments *bool    `json:"strip_comments,omitempty" desc:"remove comments"`
+	SqueezeBlank  *bool    `json:"squeeze_blank,omitempty" desc:"collapse runs of blank lines"`
+	LineNumbers   *bool    `json:"line_numbers,omitempty" desc:"prefix lines with their line numbers"`
+	MaxLines      *int     `json:"max_lines,omitempty" desc:"truncate files longer than this many lines"`
+	Head          *int     `json:"head,omitempty" desc:"keep the first N lines of each file (of files over max_lines, if set)"`
+	Tail          *int     `json:"tail,omitempty" desc:"keep the last N lines of each file (of files over max_lines, if set)"`
+	MaxTokens     *int     `json:"max_tokens,omitempty" desc:"token budget for the whole dump"`
+	IncludeBinary *string  `json:"include_binary,omitempty" desc:"how to dump binary files" enum:"skip,hex,base64,placeholder"`
+	ChangedSince  *string  `json:"changed_since,omitempty" desc:"only files git reports as changed against this ref"`
+	Staged        *bool    `json:"staged,omitempty" desc:"only files with staged changes"`
+	Unstaged      *bool    `json:"unstaged,omitempty" desc:"only files with unstaged changes"`
+	Diff          *bool    `json:"diff,omitempty" desc:"include each changed file's unified diff"`
+	Meta          []string `json:"meta,omitempty" desc:"attributes to add to each file: hash, size, lines, lang, mtime, blob"`
+}
+
+func (a *dumpArgs) apply(opts *Options) error {
+	if err := a.listArgs.apply(opts); err != nil {
+		return err
+	}
+	opts.List = false
+	override(&opts.Format, a.OutFmt)
+	override(&opts.Filter, a.Filter)
+	override(&opts.Context, a.Context)
+	override(&opts.Tree, a.Tree)
+	override(&opts.Outline, a.Outline)
+	override(&opts.StripComments, a.StripComments)
+	override(&opts.SqueezeBlank, a.SqueezeBlank)
+	override(&opts.LineNumbers, a.LineNumbers)
+	override(&opts.MaxLines, a.MaxLines)
+	override(&opts.Head, a.Head)
+	override(&opts.Tail, a.Tail)
+	override(&opts.MaxTokens, a.MaxTokens)
+	override(&opts.IncludeBinary, a.IncludeBinary)
+	override(&opts.ChangedSince, a.ChangedSince)
+	override(&opts.Staged, a.Staged)
+	override(&opts.Unstaged, a.Unstaged)
+	override(&opts.Diff, a.Diff)
+	overrideList(&opts.Meta, a.Meta)
+	return nil
+}
+
+type treeArgs struct {
+	fileArgs
+	OutFmt *string `json:"out_fmt,omitempty" desc:"output format" enum:"xml,xml-strict,md,json,jsonl"`
+}
+
+func (a *treeArgs) apply(opts *Options) error {
+	override(&opts.Format, a.OutFmt)
+	return a.fileArgs.apply(opts)
+}
+
+type tmuxArgs struct {
+	Tmux      []string `json:"tmux,omitempty" desc:"panes to capture: current, all (current window), %id, win.pane or @pane_id (default: current)"`
+	TmuxLines *int     `json:"tmux_lines,omitempty" desc:"history lines per pane (default 500; 0 = full)"`
+	Filter    *string  `json:"filter,omitempty" desc:"drop lines matching this regex"`
+	OutFmt    *string  `json:"out_fmt,omitempty" desc:"output format" enum:"xml,xml-strict,md,json,jsonl"`
+}
+
+func (a *tmuxArgs) apply(opts *Options) error {
+	opts.TmuxSelectors = a.Tmux
+	if len(opts.TmuxSelectors) == 0 {
+		opts.TmuxSelectors = []string{"current"}
+	}
+	override(&opts.TmuxLines, a.TmuxLines)
+	override(&opts.Format, a.OutFmt)
+	override(&opts.Filter, a.Filter)
+	return nil
+}
+
+type urlArgs struct {
+	URL     []string `json:"url" desc:"URLs to fetch via the Exa API" required:"true"`
+	Live    *bool    `json:"live,omitempty" desc:"force fresh content instead of cached"`
+	Timeout *int     `json:"timeout,omitempty" desc:"timeout in seconds per URL (default 15)"`
+	OutFmt  *string  `json:"out_fmt,omitempty" desc:"output format" enum:"xml,xml-strict,md,json,jsonl"`
+}
+
+func (a *urlArgs) apply(opts *Options) error {
+	if len(a.URL) == 0 {
+		return errors.New("url is required")
+	}
+	opts.URLs = a.URL
+	override(&opts.LiveCrawl, a.Live)
+	if a.Timeout != nil && *a.Timeout > 0 {
+		opts.URLTimeout = time.Duration(*a.Timeout) * time.Second
+	}
+	override(&opts.Format, a.OutFmt)
+	return nil
+}
+
+// mcpTools returns the tools the server exposes.
+func mcpTools() []*mcpTool {
+	tools := []*mcpTool{
+		{
+			Name:        "dump_directory",
+			Description: "Dump the text files of directories into one document for an LLM context window, respecting .gitignore and skipping binary, generated and lock files.",
+			newArgs:     func() toolArgs { return &dumpArgs{} },
+		},
+		{
+			Name:        "list_files",
+			Description: "List the paths of the files dump_directory would include, one per line.",
+			newArgs:     func() toolArgs { return &listArgs{} },
+		},
+		{
+			Name:        "tree",
+			Description: "Show the directory tree of the files dump_directory would include, without their contents.",
+			newArgs:     func() toolArgs { return &treeArgs{} },
+			tree:        true,
+		},
+		{
+			Name:        "capture_tmux",
+			Description: "Capture the scrollback of tmux panes.",
+			newArgs:     func() toolArgs { return &tmuxArgs{} },
+		},
+		{
+			Name:        "fetch_url",
+			Description: "Fetch the content of web pages as text via the Exa API.",
+			newArgs:     func() toolArgs { return &urlArgs{} },
+		},
+	}
+	for _, t := range tools {
+		t.InputSchema = argsSchema(reflect.TypeOf(t.newArgs()).Elem())
+	}
+	return tools
+}
+
+// argsSchema derives the JSON schema of an arguments struct from the json, desc,
+// enum and required tags of its fields, including those of embedded structs.
+func argsSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				add(f.Type)
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			prop := map[string]any{"description": f.Tag.Get("desc")}
+			switch ft.Kind() {
+			case reflect.Bool:
+				prop["type"] = "boolean"
+			case reflect.Int:
+				prop["type"] = "integer"
+			case reflect.Slice:
+				prop["type"] = "array"
+				prop["items"] = map[string]any{"type": "string"}
+			default:
+				prop["type"] = "string"
+			}
+			if enum := f.Tag.Get("enum"); enum != "" {
+				prop["enum"] = strings.Split(enum, ",")
+			}
+			if f.Tag.Get("required") == "true" {
+				required = append(required, name)
+			}
+			props[name] = prop
+		}
+	}
+	add(t)
+	schema := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// mcpServer handles one MCP session.
+type mcpServer struct {
+	base  Options
+	tools []*mcpTool
+
+	writeMu sync.Mutex
+	w       io.Writer
+
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc // by request id
+	wg       sync.WaitGroup
+}
+
+// ServeMCP speaks the Model Context Protocol over r and w (newline-delimited
+// JSON-RPC 2.0, as on stdio) until r is exhausted and the calls in flight have
+// been answered, or ctx is cancelled. Tool calls start from base, which supplies
+// settings such as ExaAPIKey and Version, and run concurrently; a client can
+// cancel one with notifications/cancelled.
+func ServeMCP(ctx context.Context, r io.Reader, w io.Writer, base Options) error {
+	// the session's streams belong to the protocol
+	base.Stdin = strings.NewReader("")
+	s := &mcpServer{base: base, tools: mcpTools(), w: w, inFlight: make(map[string]context.CancelFunc)}
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			if err == io.EOF {
+				// let calls in flight finish and answer
+				s.wg.Wait()
+				return nil
+			}
+			return fmt.Errorf("failed to read MCP request: %w", err)
+		case line := <-lines:
+			s.handle(ctx, line)
+		}
+	}
+}
+
+// handle dispatches one message. Tool calls run in their own goroutine.
+func (s *mcpServer) handle(ctx context.Context, line []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.ID != nil {
+			s.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+		}
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		s.reply(req.ID, map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "dump", "version": s.base.Version},
+		}, nil)
+	case "ping":
+		s.reply(req.ID, map[string]any{}, nil)
+	case "tools/list":
+		s.reply(req.ID, map[string]any{"tools": s.tools}, nil)
+	case "tools/call":
+		s.startCall(ctx, req)
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(req.Params, &params) == nil {
+			s.mu.Lock()
+			if cancel, ok := s.inFlight[string(params.RequestID)]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	default:
+		// other notifications (initialized, ...) need no answer
+		if req.ID != nil {
+			s.reply(req.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)})
+		}
+	}
+}
+
+// startCall runs a tools/call request in the background so that it can be
+// cancelled while the server reads further messages.
+func (s *mcpServer) startCall(ctx context.Context, req rpcRequest) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()})
+		return
+	}
+	i := slices.IndexFunc(s.tools, func(t *mcpTool) bool { return t.Name == params.Name })
+	if i < 0 {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(req.ID)
+	s.mu.Lock()
+	s.inFlight[key] = cancel
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		result := s.callTool(ctx, s.tools[i], params.Arguments)
+		s.mu.Lock()
+		delete(s.inFlight, key)
+		s.mu.Unlock()
+		// a cancelled request gets no response
+		if ctx.Err() == nil {
+			s.reply(req.ID, result, nil)
+		}
+		cancel()
+	}()
+}
+
+// callTool runs a tool and wraps its output, or its failure, as a tool result.
+// Diagnostics such as the skipped-file report follow the output as a second item.
+func (s *mcpServer) callTool(ctx context.Context, tool *mcpTool, arguments json.RawMessage) *mcpToolResult {
+	fail := func(err error) *mcpToolResult {
+		return &mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+
+	args := tool.newArgs()
+	if len(arguments) > 0 && string(arguments) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(arguments))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(args); err != nil {
+			return fail(fmt.Errorf("invalid arguments for %s: %w", tool.Name, err))
+		}
+	}
+	opts := s.base
+	if err := args.apply(&opts); err != nil {
+		return fail(fmt.Errorf("invalid arguments for %s: %w", tool.Name, err))
+	}
+	var out, stderr bytes.Buffer
+	opts.Stderr = &stderr
+
+	run := Run
+	if tool.tree {
+		run = RunTree
+	}
+	if err := run(ctx, opts, &out); err != nil {
+		return fail(err)
+	}
+	result := &mcpToolResult{Content: []mcpContent{{Type: "text", Text: out.String()}}}
+	if stderr.Len() > 0 {
+		result.Content = append(result.Content, mcpContent{Type: "text", Text: stderr.String()})
+	}
+	return result
+}
+
+// reply writes a response as one line.
+func (s *mcpServer) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// a client that stopped reading has gone; the read loop will see it
+	_, _ = io.WriteString(s.w, marshalJSON(resp, ""))
+}
@@ -0,0 +1,262 @@
+package dump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mcpClient drives a ServeMCP session in process, one request at a time.
+type mcpClient struct {
+	t      *testing.T
+	w      io.WriteCloser
+	r      *bufio.Reader
+	nextID int
+}
+
+func newMCPClient(t *testing.T, ctx context.Context, base Options) (*mcpClient, <-chan error) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeMCP(ctx, inR, outW, base)
+		outW.Close()
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return &mcpClient{t: t, w: inW, r: bufio.NewReader(outR)}, done
+}
+
+// call sends a request and decodes the response's result into result.
+func (c *mcpClient) call(method string, params any, result any) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	req := map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	c.send(req)
+
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("reading response to %s: %v", method, err)
+	}
+	var resp struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		c.t.Fatalf("invalid response %q: %v", line, err)
+	}
+	if resp.ID != c.nextID {
+		c.t.Fatalf("response id = %d, expected %d", resp.ID, c.nextID)
+	}
+	if resp.Error == nil && result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("invalid result %s: %v", resp.Result, err)
+		}
+	}
+	return resp.Error
+}
+
+func (c *mcpClient) send(msg any) {
+	c.t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.w.Write(append(data, '\n')); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *mcpClient) callTool(name string, args any) mcpToolResult {
+	c.t.Helper()
+	var result mcpToolResult
+	if rpcErr := c.call("tools/call", map[string]any{"name": name, "arguments": args}, &result); rpcErr != nil {
+		c.t.Fatalf("tools/call %s: %s", name, rpcErr.Message)
+	}
+	return result
+}
+
+func TestServeMCP(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":       "package main\n\nfunc main() {}\n",
+		"util/util.go":  "package util\n",
+		"notes.md":      "# notes\n",
+		"gen/api.pb.go": "package gen\n",
+	}
+	writeFiles(t, dir, files)
+	base := filepath.Base(dir)
+
+	c, done := newMCPClient(t, context.Background(), DefaultOptions())
+
+	var initResult struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	if rpcErr := c.call("initialize", map[string]any{"protocolVersion": "2025-03-26"}, &initResult); rpcErr != nil {
+		t.Fatalf("initialize: %s", rpcErr.Message)
+	}
+	if initResult.ProtocolVersion != "2025-03-26" || initResult.ServerInfo.Name != "dump" {
+		t.Errorf("initialize = %+v", initResult)
+	}
+	// notifications get no response; the next call would read it otherwise
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"})
+
+	var listResult struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if rpcErr := c.call("tools/list", nil, &listResult); rpcErr != nil {
+		t.Fatalf("tools/list: %s", rpcErr.Message)
+	}
+	var names []string
+	for _, tool := range listResult.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("%s input schema = %v", tool.Name, tool.InputSchema)
+		}
+	}
+	if got := strings.Join(names, ","); got != "dump_directory,list_files,tree,capture_tmux,fetch_url" {
+		t.Errorf("tools = %s", got)
+	}
+
+	t.Run("list_files", func(t *testing.T) {
+		result := c.callTool("list_files", map[string]any{"dir": []string{dir}, "ext": []string{"go"}})
+		expected := filepath.Join(base, "main.go") + "\n" + filepath.Join(base, "util", "util.go") + "\n"
+		if result.IsError || result.Content[0].Text != expected {
+			t.Errorf("list_files = %+v, expected %q", result, expected)
+		}
+		// the skipped generated file is reported after the listing
+		if len(result.Content) != 2 || !strings.Contains(result.Content[1].Text, "api.pb.go: generated") {
+			t.Errorf("Expected a skip report, got %+v", result.Content)
+		}
+	})
+
+	t.Run("dump_directory", func(t *testing.T) {
+		result := c.callTool("dump_directory", map[string]any{"dir": []string{dir}, "glob": []string{"*.md"}, "out_fmt": "md"})
+		expected := "```" + filepath.Join(base, "notes.md") + "\n# notes\n```"
+		if result.IsError || !strings.Contains(result.Content[0].Text, expected) {
+			t.Errorf("dump_directory = %+v, expected %q", result, expected)
+		}
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		result := c.callTool("tree", map[string]any{"dir": []string{dir}, "include_generated": true})
+		text := result.Content[0].Text
+		if result.IsError || !strings.Contains(text, "api.pb.go") || strings.Contains(text, "package main") {
+			t.Errorf("tree = %+v", result)
+		}
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		result := c.callTool("list_files", map[string]any{"bogus": true})
+		if !result.IsError || !strings.Contains(result.Content[0].Text, `unknown field "bogus"`) {
+			t.Errorf("list_files = %+v", result)
+		}
+		result = c.callTool("dump_directory", map[string]any{"out_fmt": "yaml"})
+		if !result.IsError {
+			t.Errorf("Expected an error for out_fmt yaml, got %+v", result)
+		}
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		rpcErr := c.call("tools/call", map[string]any{"name": "nope"}, nil)
+		if rpcErr == nil || rpcErr.Code != rpcInvalidParams {
+			t.Errorf("Expected invalid params, got %+v", rpcErr)
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		rpcErr := c.call("resources/list", nil, nil)
+		if rpcErr == nil || rpcErr.Code != rpcMethodNotFound {
+			t.Errorf("Expected method not found, got %+v", rpcErr)
+		}
+	})
+
+	c.w.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServeMCP = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeMCP did not return at the end of its input")
+	}
+}
+
+func TestServeMCPBaseOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n", "notes.md": "# notes\n"})
+	base := filepath.Base(dir)
+
+	// the defaults from the config files and profile
+	opts := DefaultOptions()
+	opts.Exts = []string{"go"}
+	opts.LineNumbers = true
+	c, _ := newMCPClient(t, context.Background(), opts)
+
+	testCases := []struct {
+		name     string
+		args     map[string]any
+		expected string
+	}{
+		{"Arguments left out keep the defaults", map[string]any{"dir": []string{dir}},
+			"<document path='" + base + "/main.go' line_numbers='true'>\n1  package main\n</document>\n"},
+		{"Arguments sent override them", map[string]any{"dir": []string{dir}, "ext": []string{"md"}, "line_numbers": false},
+			"<document path='" + base + "/notes.md'>\n# notes\n</document>\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := c.callTool("dump_directory", tc.args)
+			if result.IsError || len(result.Content) == 0 || result.Content[0].Text != tc.expected {
+				t.Errorf("dump_directory = %+v, expected %q", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestServeMCPAnswersPendingCallsAtEOF(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var in strings.Builder
+	for id := 1; id <= 3; id++ {
+		fmt.Fprintf(&in, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"list_files","arguments":{"dir":[%q]}}}`+"\n", id, dir)
+	}
+	var out strings.Builder
+	if err := ServeMCP(context.Background(), strings.NewReader(in.String()), &out, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), `"isError":false`); n != 3 {
+		t.Errorf("Expected 3 results, got:\n%s", out.String())
+	}
+}
+
+func TestServeMCPCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, done := newMCPClient(t, ctx, DefaultOptions())
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("ServeMCP = %v, expected %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeMCP did not return after cancellation")
+	}
+}
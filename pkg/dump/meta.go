@@ -0,0 +1,167 @@
+package dump
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaFields are the --meta attributes, in the order they are shown.
+var metaFields = []string{"hash", "size", "lines", "lang", "mtime", "blob"}
+
+// FileMeta holds the --meta attributes of a file as it is on disk, before any
+// transform or filter; fields that were not asked for are left unset.
+type FileMeta struct {
+	Hash  string `json:"hash,omitempty"`  // sha256 of the content
+	Size  *int64 `json:"size,omitempty"`  // in bytes
+	Lines *int   `json:"lines,omitempty"` // not set for binary files
+	Lang  string `json:"lang,omitempty"`  // language, by file name
+	MTime string `json:"mtime,omitempty"` // RFC 3339, UTC
+	Blob  string `json:"blob,omitempty"`  // git blob id, in a git work tree
+}
+
+// attrs returns the set fields as name/value pairs, in metaFields order.
+func (m *FileMeta) attrs() [][2]string {
+	var attrs [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			attrs = append(attrs, [2]string{name, value})
+		}
+	}
+	add("hash", m.Hash)
+	if m.Size != nil {
+		add("size", strconv.FormatInt(*m.Size, 10))
+	}
+	if m.Lines != nil {
+		add("lines", strconv.Itoa(*m.Lines))
+	}
+	add("lang", m.Lang)
+	add("mtime", m.MTime)
+	add("blob", m.Blob)
+	return attrs
+}
+
+// languagesByExt name the language of a file by its extension, as in the info
+// string of a markdown code block.
+var languagesByExt = map[string]string{
+	".go": "go", ".py": "python", ".pyi": "python", ".rb": "ruby", ".rs": "rust",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "tsx",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hh": "cpp", ".hpp": "cpp", ".hxx": "cpp",
+	".m": "objective-c", ".mm": "objective-cpp", ".java": "java", ".kt": "kotlin", ".kts": "kotlin",
+	".scala": "scala", ".swift": "swift", ".dart": "dart", ".cs": "csharp", ".fs": "fsharp",
+	".php": "php", ".lua": "lua", ".zig": "zig", ".nim": "nim", ".ex": "elixir", ".exs": "elixir",
+	".erl": "erlang", ".hs": "haskell", ".ml": "ocaml", ".clj": "clojure", ".r": "r", ".jl": "julia",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish", ".ps1": "powershell",
+	".sql": "sql", ".proto": "protobuf", ".graphql": "graphql", ".tf": "hcl", ".hcl": "hcl", ".nix": "nix",
+	".html": "html", ".htm": "html", ".css": "css", ".scss": "scss", ".less": "less",
+	".vue": "vue", ".svelte": "svelte", ".xml": "xml", ".svg": "svg",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".ini": "ini",
+	".md": "markdown", ".rst": "rst", ".tex": "latex", ".txt": "text",
+}
+
+// languagesByName name the language of files known by their whole name.
+var languagesByName = map[string]string{
+	"Dockerfile": "dockerfile", "Containerfile": "dockerfile", "Makefile": "makefile",
+	"GNUmakefile": "makefile", "CMakeLists.txt": "cmake", "Justfile": "just", "justfile": "just",
+	"go.mod": "go-mod", "Gemfile": "ruby", "Rakefile": "ruby",
+}
+
+// detectLanguage names the language of the file at path, or returns "".
+func detectLanguage(path string) string {
+	name := filepath.Base(path)
+	if lang, ok := languagesByName[name]; ok {
+		return lang
+	}
+	if strings.HasPrefix(name, "Dockerfile.") {
+		return "dockerfile"
+	}
+	return languagesByExt[strings.ToLower(filepath.Ext(name))]
+}
+
+// gitBlobID is the id git gives a blob with data as its content, as computed by
+// `git hash-object` (without clean filters).
+func gitBlobID(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// repoCache remembers which directories lie in a git work tree.
+type repoCache struct {
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+// inRepo reports whether dir, an absolute path, lies in a git work tree.
+func (c *repoCache) inRepo(dir string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirs == nil {
+		c.dirs = make(map[string]bool)
+	}
+	var visited []string
+	found := false
+	for {
+		if known, ok := c.dirs[dir]; ok {
+			found = known
+			break
+		}
+		visited = append(visited, dir)
+		// .git is a directory, or a file in worktrees and submodules
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			found = true
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for _, d := range visited {
+		c.dirs[d] = found
+	}
+	return found
+}
+
+// fileMeta returns the --meta attributes of the file at path with content data,
+// or nil when none were asked for.
+func (e *Env) fileMeta(path string, data []byte, binary bool) *FileMeta {
+	if len(e.meta) == 0 {
+		return nil
+	}
+	m := &FileMeta{}
+	if e.meta["hash"] {
+		m.Hash = contentHash(data)
+	}
+	if e.meta["size"] {
+		size := int64(len(data))
+		m.Size = &size
+	}
+	if e.meta["lines"] && !binary {
+		lines := countLines(string(data))
+		m.Lines = &lines
+	}
+	if e.meta["lang"] && !binary {
+		m.Lang = detectLanguage(path)
+	}
+	if e.meta["mtime"] {
+		if info, err := os.Stat(path); err == nil {
+			m.MTime = info.ModTime().UTC().Format(time.RFC3339)
+		}
+	}
+	if e.meta["blob"] {
+		if abs, err := filepath.Abs(path); err == nil && e.repos.inRepo(filepath.Dir(abs)) {
+			m.Blob = gitBlobID(data)
+		}
+	}
+	return m
+}
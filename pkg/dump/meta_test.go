@@ -0,0 +1,116 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := map[string]string{
+		"main.go":           "go",
+		"src/App.TSX":       "tsx",
+		"Dockerfile":        "dockerfile",
+		"Dockerfile.dev":    "dockerfile",
+		"build/Makefile":    "makefile",
+		"notes.unknownext":  "",
+		"README":            "",
+		"config/dump.yaml":  "yaml",
+		"scripts/deploy.sh": "bash",
+	}
+	for path, expected := range testCases {
+		if got := detectLanguage(path); got != expected {
+			t.Errorf("detectLanguage(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
+
+func TestGitBlobID(t *testing.T) {
+	// as printed by `git hash-object`
+	testCases := map[string]string{
+		"":              "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"hello world\n": "3b18e512dba79e4c8300dd08aeb37f8e728b8dad",
+	}
+	for data, expected := range testCases {
+		if got := gitBlobID([]byte(data)); got != expected {
+			t.Errorf("gitBlobID(%q) = %s, expected %s", data, got, expected)
+		}
+	}
+}
+
+func TestRunMeta(t *testing.T) {
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "hello world\n"
+	writeFiles(t, parent, map[string]string{"repo/hello.go": content, "plain/a.txt": content})
+	path := filepath.Join(repo, "hello.go")
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(parent, "plain")
+
+	dump := func(format string, dirs ...string) string {
+		t.Helper()
+		opts := DefaultOptions()
+		opts.Dirs = dirs
+		opts.Format = format
+		opts.Meta = []string{"mtime", "hash", "size", "lines", "lang", "blob"}
+		var out bytes.Buffer
+		if err := Run(context.Background(), opts, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	hash := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	blob := "3b18e512dba79e4c8300dd08aeb37f8e728b8dad"
+
+	expected := "<document path='repo/hello.go' hash='" + hash + "' size='12' lines='1' lang='go' mtime='2024-05-01T12:00:00Z' blob='" + blob + "'>\n"
+	if got := dump("xml", repo); !strings.HasPrefix(got, expected) {
+		t.Errorf("xml = %q, expected it to start with %q", got, expected)
+	}
+	expected = "```repo/hello.go hash=" + hash + " size=12 lines=1 lang=go mtime=2024-05-01T12:00:00Z blob=" + blob + "\n"
+	if got := dump("md", repo); !strings.HasPrefix(got, expected) {
+		t.Errorf("md = %q, expected it to start with %q", got, expected)
+	}
+
+	var doc jsonDocument
+	if err := json.Unmarshal([]byte(dump("json", repo, plain)), &doc); err != nil {
+		t.Fatal(err)
+	}
+	meta := doc.Files[0].Meta
+	if meta == nil || meta.Hash != hash || *meta.Size != 12 || *meta.Lines != 1 || meta.Lang != "go" || meta.Blob != blob {
+		t.Errorf("json meta = %+v", meta)
+	}
+	// outside a git work tree there is no blob id
+	if meta := doc.Files[1].Meta; meta == nil || meta.Blob != "" || meta.Lang != "text" {
+		t.Errorf("json meta outside a repository = %+v", meta)
+	}
+
+	// a dump with hashes can be unpacked, but not once the file has changed
+	files, err := ParseDump([]byte(dump("xml-strict", repo)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unpack(files, UnpackOptions{Root: parent, DryRun: true}, &bytes.Buffer{}); err != nil {
+		t.Errorf("Unpack of an unchanged file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unpack(files, UnpackOptions{Root: parent}, nil); err == nil {
+		t.Error("Expected Unpack to refuse a file changed since the dump")
+	}
+	files[0].Hash = ""
+	if err := Unpack(files, UnpackOptions{Root: parent}, nil); err == nil {
+		t.Error("Expected Unpack to refuse a changed file by its blob id")
+	}
+}
@@ -0,0 +1,86 @@
+package dump
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// outliners find the function bodies to elide from a source file, by file
+// extension, as byte ranges of src in order. Files in other languages, and files
+// that fail to parse, keep their full content.
+var outliners = map[string]func(path, src string) ([][2]int, bool){
+	".go": outlineGo,
+}
+
+// outlineContent is the contentTransformer for --outline. Each elided body joins
+// the line it starts on with the rest of the line it ends on.
+func outlineContent(path string, lines []contentLine) ([]contentLine, error) {
+	outline, ok := outliners[strings.ToLower(filepath.Ext(path))]
+	if !ok || len(lines) == 0 {
+		return lines, nil
+	}
+	src := lineTexts(lines)
+	bodies, ok := outline(path, src)
+	if !ok {
+		return lines, nil
+	}
+
+	var out []contentLine
+	var sb strings.Builder
+	pos, line, num := 0, 0, lines[0].num
+	// copyTo copies src up to end, ending output lines at line breaks
+	copyTo := func(end int) {
+		for pos < end {
+			j := strings.IndexByte(src[pos:end], '\n')
+			if j < 0 {
+				sb.WriteString(src[pos:end])
+				pos = end
+				return
+			}
+			sb.WriteString(src[pos : pos+j])
+			out = append(out, contentLine{num: num, text: sb.String()})
+			sb.Reset()
+			pos += j + 1
+			if line++; line < len(lines) {
+				num = lines[line].num
+			}
+		}
+	}
+	for _, b := range bodies {
+		copyTo(b[0])
+		sb.WriteString(elidedBody)
+		line += strings.Count(src[b[0]:b[1]], "\n")
+		pos = b[1]
+	}
+	copyTo(len(src))
+	return out, nil
+}
+
+// elidedBody replaces function bodies in an outline.
+const elidedBody = "{ ... }"
+
+// outlineGo returns the bodies of a Go file's top-level functions, so that its
+// package clause, imports, declarations, signatures and comments outside function
+// bodies remain.
+func outlineGo(path, src string) ([][2]int, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+
+	// top-level function bodies in source order; closures inside them go with the body
+	var bodies [][2]int
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			bodies = append(bodies, [2]int{
+				fset.Position(fn.Body.Lbrace).Offset,
+				fset.Position(fn.Body.Rbrace).Offset + 1,
+			})
+		}
+	}
+	return bodies, true
+}
@@ -0,0 +1,122 @@
+package dump
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutlineContent(t *testing.T) {
+	goSrc := `// Package shapes has shapes.
+package shapes
+
+import "math"
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is round.
+type Circle struct {
+	R float64 // radius
+}
+
+const Pi = math.Pi
+
+// Area returns the area.
+func (c Circle) Area() float64 {
+	// inside the body
+	return Pi * c.R * c.R
+}
+
+func helper[T any](xs []T, f func(T) bool) (n int) {
+	for _, x := range xs {
+		if f(x) {
+			n++
+		}
+	}
+	return n
+}
+
+//go:noinline
+func External(x int) int
+`
+	expected := `// Package shapes has shapes.
+package shapes
+
+import "math"
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is round.
+type Circle struct {
+	R float64 // radius
+}
+
+const Pi = math.Pi
+
+// Area returns the area.
+func (c Circle) Area() float64 { ... }
+
+func helper[T any](xs []T, f func(T) bool) (n int) { ... }
+
+//go:noinline
+func External(x int) int
+`
+
+	testCases := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{"Go file", "shapes.go", goSrc, expected},
+		{"Unsupported language", "main.py", "def f():\n    return 1\n", "def f():\n    return 1\n"},
+		{"Invalid Go keeps full content", "broken.go", "package x\nfunc {\n", "package x\nfunc {\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := transformString(outlineContent, tc.path, tc.content)
+			if err != nil {
+				t.Fatalf("outlineContent: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("outlineContent(%s) = %q, expected %q", tc.path, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDirectoryOutline(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":      "package a\n\n// F does things.\nfunc F() {\n\tprintln(\"secret\")\n}\n",
+		"notes.txt": "kept in full\n",
+	}
+	writeFiles(t, dir, files)
+
+	// the filter runs on the outline, so it sees the elided body
+	env, err := newEnv(&Options{Outline: true, Filter: `^// F`, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := (&DirectorySource{Dir: dir}).Collect(context.Background(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, b := range blocks {
+		got[filepath.Base(b.Item.Path)] = b.Item.Content
+	}
+	if got["a.go"] != "package a\n\nfunc F() { ... }\n" {
+		t.Errorf("a.go = %q", got["a.go"])
+	}
+	if got["notes.txt"] != "kept in full\n" {
+		t.Errorf("notes.txt = %q", got["notes.txt"])
+	}
+}
@@ -0,0 +1,153 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// clipboardTool is a command that reads text on stdin into the system clipboard.
+type clipboardTool struct {
+	name string
+	args []string
+	// display is the environment variable the tool needs to reach a display
+	display string
+}
+
+// clipboardTools are tried in order; the first that succeeds receives the dump.
+var clipboardTools = []clipboardTool{
+	{name: "wl-copy", display: "WAYLAND_DISPLAY"},
+	{name: "xclip", args: []string{"-selection", "clipboard"}, display: "DISPLAY"},
+	{name: "xsel", args: []string{"--clipboard", "--input"}, display: "DISPLAY"},
+	{name: "pbcopy"},
+}
+
+// ttyPath is where OSC 52 sequences are written; tests point it at a file.
+var ttyPath = "/dev/tty"
+
+// deliver writes a rendered dump to opts.Output and the clipboard and reports
+// its size on stderr.
+func deliver(opts *Options, data []byte) error {
+	stderr := opts.Stderr
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	tok, err := NewTokenizer(opts.Tokenizer)
+	if err != nil {
+		return err
+	}
+	size := fmt.Sprintf("%d bytes, %d tokens", len(data), tok.Count(string(data)))
+
+	if opts.Output != "" {
+		if err := writeFileAtomic(opts.Output, data); err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "wrote %s to %s\n", size, opts.Output)
+	}
+	if opts.Copy {
+		via, err := copyToClipboard(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "copied %s to the clipboard via %s\n", size, via)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partial dump. An existing file keeps its mode.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("failed to write %s: not a regular file", path)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// copyToClipboard puts data on the clipboard with the first clipboard tool that
+// works. Inside tmux or over SSH, where the tools usually cannot reach the user's
+// clipboard, it falls back to an OSC 52 escape sequence on the terminal.
+// It returns the name of the method used.
+func copyToClipboard(data []byte) (string, error) {
+	var tried []string
+	for _, tool := range clipboardTools {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		if tool.display != "" && os.Getenv(tool.display) == "" {
+			continue
+		}
+		if _, err := runCmdInput(bytes.NewReader(data), tool.name, tool.args...); err != nil {
+			tried = append(tried, fmt.Sprintf("%s: %v", tool.name, err))
+			continue
+		}
+		return tool.name, nil
+	}
+
+	if os.Getenv("TMUX") != "" || os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		if err := writeOSC52(data); err != nil {
+			return "", err
+		}
+		return "OSC 52", nil
+	}
+	if len(tried) > 0 {
+		return "", fmt.Errorf("failed to copy to the clipboard (%s)", strings.Join(tried, "; "))
+	}
+	return "", fmt.Errorf("no clipboard tool found (install wl-copy, xclip, xsel or pbcopy)")
+}
+
+// writeOSC52 asks the terminal to set its clipboard. Inside tmux the sequence is
+// wrapped for passthrough to the outer terminal, which needs allow-passthrough on.
+func writeOSC52(data []byte) error {
+	tty, err := os.OpenFile(ttyPath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open terminal for OSC 52: %w", err)
+	}
+	defer tty.Close()
+	seq := osc52(data, os.Getenv("TMUX") != "")
+	if _, err := io.WriteString(tty, seq); err != nil {
+		return fmt.Errorf("failed to write OSC 52 sequence: %w", err)
+	}
+	return nil
+}
+
+// osc52 returns the escape sequence that sets the clipboard to data.
+func osc52(data []byte, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(data) + "\a"
+	if tmux {
+		// DCS passthrough: escapes inside it are doubled
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
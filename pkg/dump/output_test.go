@@ -0,0 +1,184 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.xml")
+
+	if err := writeFileAtomic(path, []byte("first\n")); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("New file mode = %v, expected 0644", info.Mode().Perm())
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("second\n")); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "second\n" {
+		t.Errorf("Content = %q, expected %q", data, "second\n")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("Overwritten file mode = %v, expected 0600 to be kept", info.Mode().Perm())
+	}
+
+	if err := writeFileAtomic(dir, []byte("x")); err == nil {
+		t.Error("Expected an error when the target is a directory")
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "out.xml"), []byte("x")); err == nil {
+		t.Error("Expected an error when the parent directory does not exist")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only out.xml in the directory, found %d entries", len(entries))
+	}
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat not found")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	for _, name := range []string{"TMUX", "SSH_TTY", "SSH_CONNECTION", "DISPLAY", "WAYLAND_DISPLAY"} {
+		t.Setenv(name, "")
+	}
+	tty := filepath.Join(t.TempDir(), "tty")
+	if err := os.WriteFile(tty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldTTY := ttyPath
+	ttyPath = tty
+	defer func() { ttyPath = oldTTY }()
+
+	t.Run("No tool outside tmux and SSH", func(t *testing.T) {
+		if _, err := copyToClipboard([]byte("x")); err == nil {
+			t.Error("Expected an error with no clipboard tool")
+		}
+	})
+
+	t.Run("OSC 52 fallback over SSH", func(t *testing.T) {
+		t.Setenv("SSH_TTY", "/dev/pts/1")
+		via, err := copyToClipboard([]byte("hello"))
+		if err != nil {
+			t.Fatalf("copyToClipboard: %v", err)
+		}
+		data, _ := os.ReadFile(tty)
+		if via != "OSC 52" || string(data) != "\x1b]52;c;aGVsbG8=\a" {
+			t.Errorf("Copied via %q writing %q", via, data)
+		}
+	})
+
+	t.Run("Clipboard tool", func(t *testing.T) {
+		clip := filepath.Join(t.TempDir(), "clipboard")
+		script := "#!/bin/sh\n" + cat + " > " + clip + "\n"
+		if err := os.WriteFile(filepath.Join(bin, "wl-copy"), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		// wl-copy is skipped without a Wayland display
+		if _, err := copyToClipboard([]byte("x")); err == nil {
+			t.Error("Expected wl-copy to be skipped without WAYLAND_DISPLAY")
+		}
+
+		t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+		via, err := copyToClipboard([]byte("hello\n"))
+		if err != nil {
+			t.Fatalf("copyToClipboard: %v", err)
+		}
+		data, _ := os.ReadFile(clip)
+		if via != "wl-copy" || string(data) != "hello\n" {
+			t.Errorf("Copied via %q, clipboard holds %q", via, data)
+		}
+	})
+}
+
+func TestOSC52(t *testing.T) {
+	if got, expected := osc52([]byte("hi"), false), "\x1b]52;c;aGk=\a"; got != expected {
+		t.Errorf("osc52 = %q, expected %q", got, expected)
+	}
+	if got, expected := osc52([]byte("hi"), true), "\x1bPtmux;\x1b\x1b]52;c;aGk=\a\x1b\\"; got != expected {
+		t.Errorf("osc52 in tmux = %q, expected %q", got, expected)
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.xml")
+	opts := DefaultOptions()
+	opts.Sources = []Source{&staticSource{items: []*Item{{Path: "a.go", Content: "package a\n"}}}}
+	opts.Output = path
+	opts.Tokenizer = "chars"
+	var stderr, out bytes.Buffer
+	opts.Stderr = &stderr
+
+	if err := Run(context.Background(), opts, &out); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing on the writer, got %q", out.String())
+	}
+	expected := "<document path='a.go'>\npackage a\n</document>\n"
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("Output file = %q, expected %q", data, expected)
+	}
+	if got := stderr.String(); !strings.Contains(got, "wrote 45 bytes, 12 tokens to "+path) {
+		t.Errorf("Unexpected report %q", got)
+	}
+
+	opts.SplitBytes, opts.OutDir = 1000, t.TempDir()
+	if err := Run(context.Background(), opts, &out); err == nil {
+		t.Error("Expected an error combining --output with a split dump")
+	}
+}
+
+func TestRunOutputInsideWalk(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "proj")
+	writeFiles(t, dir, map[string]string{"a.txt": "a\n"})
+	expected := "<document path='proj/a.txt'>\na\n</document>\n"
+
+	// a second run doesn't dump the output of the first
+	opts := DefaultOptions()
+	opts.Dirs = []string{dir}
+	opts.Output = filepath.Join(dir, "out.xml")
+	opts.Stderr = &bytes.Buffer{}
+	for i := 0; i < 2; i++ {
+		if err := Run(context.Background(), opts, &bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, _ := os.ReadFile(opts.Output); string(data) != expected {
+		t.Errorf("Output file = %q, expected %q", data, expected)
+	}
+
+	if err := os.Remove(opts.Output); err != nil {
+		t.Fatal(err)
+	}
+	opts.Output = ""
+	opts.SplitBytes, opts.OutDir = 1000, filepath.Join(dir, "parts")
+	for i := 0; i < 2; i++ {
+		if err := Run(context.Background(), opts, &bytes.Buffer{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(opts.OutDir, "part-001.xml"))
+	if !strings.HasSuffix(string(data), expected) || strings.Contains(string(data), "parts/") {
+		t.Errorf("Part file = %q, expected only proj/a.txt", data)
+	}
+}
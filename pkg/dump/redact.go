@@ -0,0 +1,225 @@
+package dump
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// secretRule detects one shape of credential.
+type secretRule struct {
+	name string
+	re   *regexp.Regexp
+	// group is the submatch holding the secret; 0 redacts the whole match
+	group int
+	// minEntropy skips low-entropy matches such as placeholders (bits per byte)
+	minEntropy float64
+	// mixed requires both letters and digits, which identifiers rarely have
+	mixed bool
+}
+
+// builtinSecretRules are checked in order; assignments go last so they do not
+// claim values a more specific rule would name.
+var builtinSecretRules = []secretRule{
+	{name: "private_key", re: regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----.*?-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)},
+	{name: "aws_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{name: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{
+		name:       "secret",
+		re:         regexp.MustCompile(`(?i)[a-z0-9_.-]*(?:secret|token|passw(?:or)?d|api_?key|access_?key|auth|credential)[a-z0-9_.-]*["']?\s*[:=]\s*["']?([a-z0-9+/=_.~-]{16,})`),
+		group:      1,
+		minEntropy: 3.5,
+		mixed:      true,
+	},
+}
+
+// redactedPrefix starts every placeholder, so later rules leave them alone.
+const redactedPrefix = "[REDACTED:"
+
+// redactor replaces secrets with numbered placeholders such as [REDACTED:aws_key#1].
+// The same secret gets the same placeholder everywhere in a dump.
+type redactor struct {
+	rules        []secretRule
+	placeholders map[string]string // secret → placeholder
+	next         map[string]int    // rule name → last number used
+}
+
+// redactionReport counts the secrets redacted from one block.
+type redactionReport struct {
+	label string
+	kinds map[string]int
+	total int
+}
+
+// newRedactor compiles the built-in rules plus the user's: patterns are plain
+// regexes named custom, rules are name=REGEX. A regex with a capture group
+// redacts only the first group.
+func newRedactor(patterns, rules []string) (*redactor, error) {
+	r := &redactor{placeholders: make(map[string]string), next: make(map[string]int)}
+	var custom []secretRule
+	add := func(flag, name, p string) error {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", flag, p, err)
+		}
+		rule := secretRule{name: name, re: re}
+		if re.NumSubexp() > 0 {
+			rule.group = 1
+		}
+		custom = append(custom, rule)
+		return nil
+	}
+	for _, rule := range rules {
+		name, p, ok := strings.Cut(rule, "=")
+		if !ok || !redactNameRgx.MatchString(name) {
+			return nil, fmt.Errorf("invalid --redact-rule %q (must be name=REGEX, with a lowercase name)", rule)
+		}
+		if err := add("--redact-rule", name, p); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range patterns {
+		if err := add("--redact-pattern", "custom", p); err != nil {
+			return nil, err
+		}
+	}
+	// user patterns run before the generic assignment rule
+	n := len(builtinSecretRules) - 1
+	r.rules = append(r.rules, builtinSecretRules[:n]...)
+	r.rules = append(r.rules, custom...)
+	r.rules = append(r.rules, builtinSecretRules[n:]...)
+	return r, nil
+}
+
+var redactNameRgx = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// redact replaces every secret in s, adding the rule names it hit to kinds.
+func (r *redactor) redact(s string, kinds map[string]int) string {
+	for _, rule := range r.rules {
+		matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[2*rule.group], m[2*rule.group+1]
+			if start < 0 {
+				continue
+			}
+			secret := s[start:end]
+			if strings.Contains(secret, redactedPrefix) || shannonEntropy(secret) < rule.minEntropy {
+				continue
+			}
+			if rule.mixed && (!strings.ContainsAny(secret, "0123456789") || !strings.ContainsFunc(secret, unicode.IsLetter)) {
+				continue
+			}
+			sb.WriteString(s[last:start])
+			sb.WriteString(r.placeholder(rule.name, secret))
+			last = end
+			kinds[rule.name]++
+		}
+		sb.WriteString(s[last:])
+		s = sb.String()
+	}
+	return s
+}
+
+func (r *redactor) placeholder(name, secret string) string {
+	if p, ok := r.placeholders[secret]; ok {
+		return p
+	}
+	r.next[name]++
+	p := fmt.Sprintf("%s%s#%d]", redactedPrefix, name, r.next[name])
+	r.placeholders[secret] = p
+	return p
+}
+
+// redactBlocks redacts every block's content in output order, so placeholder
+// numbers are stable across runs, and reports the blocks that had secrets.
+func (r *redactor) redactBlocks(blocks []*Block) []redactionReport {
+	var reports []redactionReport
+	for _, b := range blocks {
+		kinds := make(map[string]int)
+		var fields []*string
+		switch {
+		case b.Tree != nil, b.Removed != nil:
+			continue
+		case b.Tmux != nil:
+			fields = []*string{&b.Tmux.Content}
+		case b.Command != nil:
+			// the command line can carry credentials too (curl -H ...)
+			fields = []*string{&b.Command.Command, &b.Command.Stdout, &b.Command.Stderr}
+		case b.Item.Binary != "":
+			// encoded binary is noise to the secret patterns
+			fields = []*string{&b.Item.Diff}
+		default:
+			fields = []*string{&b.Item.Content, &b.Item.Diff}
+		}
+		var content string
+		if b.Item != nil {
+			content = b.Item.Content
+		}
+		for _, f := range fields {
+			*f = r.redact(*f, kinds)
+		}
+		if b.Item != nil && b.Item.Content != content {
+			b.Item.Transforms = appendTransform(b.Item.Transforms, "redacted")
+		}
+		if len(kinds) == 0 {
+			continue
+		}
+		report := redactionReport{label: b.Label(), kinds: kinds}
+		for _, n := range kinds {
+			report.total += n
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// formatRedactionReport lists the redaction counts per block.
+func formatRedactionReport(reports []redactionReport) string {
+	var total int
+	for _, r := range reports {
+		total += r.total
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "redacted %d secrets:\n", total)
+	for _, r := range reports {
+		names := make([]string, 0, len(r.kinds))
+		for name := range r.kinds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = fmt.Sprintf("%s=%d", name, r.kinds[name])
+		}
+		fmt.Fprintf(&sb, "  %s: %d (%s)\n", r.label, r.total, strings.Join(parts, ", "))
+	}
+	return sb.String()
+}
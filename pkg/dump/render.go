@@ -0,0 +1,307 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Renderer turns blocks into output text.
+type Renderer interface {
+	// RenderBlock renders a single block on its own; the token budget uses it to
+	// size blocks.
+	RenderBlock(b *Block) string
+	// Render writes the complete output for blocks to w.
+	Render(w io.Writer, blocks []*Block) error
+}
+
+// NewRenderer returns the renderer for format: xml, xml-strict, md, json or jsonl.
+// tag wraps file items in xml output; version is reported in json metadata.
+func NewRenderer(format, tag, version string) (Renderer, error) {
+	switch format {
+	case "xml", "xml-strict", "md", "jsonl":
+	case "json":
+		return jsonRenderer{version: version}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format %q (must be xml, xml-strict, md, json or jsonl)", format)
+	}
+	if format == "xml-strict" && !xmlTagRgx.MatchString(tag) {
+		return nil, fmt.Errorf("invalid --xml-tag %q (must be a valid XML name)", tag)
+	}
+	return textRenderer{format: format, tag: tag}, nil
+}
+
+// textRenderer writes blocks one after another in xml, xml-strict, md or jsonl.
+type textRenderer struct {
+	format string
+	tag    string
+}
+
+func (r textRenderer) RenderBlock(b *Block) string {
+	switch {
+	case r.format == "jsonl":
+		return formatJSONRecord(b)
+	case b.Tree != nil:
+		return formatTreeOutput(b.Tree, r.format)
+	case b.Tmux != nil:
+		return formatTmuxItem(*b.Tmux, r.format)
+	case b.Command != nil:
+		return formatCommandItem(*b.Command, r.format)
+	case b.Removed != nil:
+		return formatRemovedList(*b.Removed, r.format)
+	default:
+		return formatItem(*b.Item, r.format, r.tag)
+	}
+}
+
+func (r textRenderer) Render(w io.Writer, blocks []*Block) error {
+	contents := make([]string, len(blocks))
+	for i, b := range blocks {
+		contents[i] = r.RenderBlock(b)
+	}
+	return writeContents(w, contents)
+}
+
+// jsonRenderer writes a single json document grouped by block kind.
+type jsonRenderer struct {
+	version string
+}
+
+// RenderBlock returns the block's record as the document holds it, indented
+// and followed by a comma. frame holds the rest of the document.
+func (r jsonRenderer) RenderBlock(b *Block) string {
+	return formatJSONDocumentRecord(b)
+}
+
+// frame returns the document for blocks without their records: the top-level
+// object, the arrays that hold the records and the metadata.
+func (r jsonRenderer) frame(blocks []*Block) string {
+	return formatJSONFrame(blocks, r.version)
+}
+
+func (r jsonRenderer) Render(w io.Writer, blocks []*Block) error {
+	_, err := io.WriteString(w, formatJSONDocument(blocks, r.version))
+	return err
+}
+
+// xmlAttrEscaper escapes text for use inside a quoted XML attribute.
+var xmlAttrEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"'", "&apos;",
+	"\"", "&quot;",
+)
+
+// xmlAttr escapes s for use inside a quoted XML attribute.
+func xmlAttr(s string) string {
+	return xmlAttrEscaper.Replace(xmlChars(s))
+}
+
+// xmlChars replaces the characters XML 1.0 does not allow anywhere in a document,
+// escaped or not (control characters such as ANSI escapes, and invalid UTF-8),
+// with U+FFFD.
+func xmlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r >= 0xD800 && r <= 0xDFFF, r == 0xFFFE, r == 0xFFFF:
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+// mdFence returns the fence for a markdown code block holding texts: three
+// backticks, or one more than the longest run of backticks in them, so that no
+// line of the content can close the block early.
+func mdFence(texts ...string) string {
+	longest := 2
+	for _, text := range texts {
+		run := 0
+		for i := 0; i < len(text); i++ {
+			if text[i] != '`' {
+				run = 0
+				continue
+			}
+			run++
+			longest = max(longest, run)
+		}
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// xmlTagRgx matches tag names that are safe to emit in xml-strict output.
+var xmlTagRgx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// cdata wraps content in a CDATA section, splitting any "]]>" across two sections.
+// Characters XML does not allow are replaced, so the output stays well-formed.
+func cdata(content string) string {
+	return "<![CDATA[" + strings.ReplaceAll(xmlChars(content), "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+// mdInfoPath quotes a path for the info string of a code block when it holds
+// spaces, so unpack can tell where it ends (splitMDInfo).
+func mdInfoPath(path string) string {
+	if strings.ContainsFunc(path, unicode.IsSpace) || strings.HasPrefix(path, `"`) {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+func formatItem(item Item, format string, tag string) string {
+	// numbered content is flagged so readers do not take the numbers for code,
+	// encoded binaries so they can be decoded, content that differs from the
+	// file so unpack leaves it alone, and --meta adds its attributes
+	attrs := ""
+	if item.LineNumbers {
+		attrs = " line_numbers='true'"
+	}
+	if item.Binary != "" {
+		attrs += fmt.Sprintf(" binary='%s'", item.Binary)
+	}
+	for _, name := range item.Transforms {
+		attrs += fmt.Sprintf(" %s='true'", name)
+	}
+	var meta [][2]string
+	if item.Meta != nil {
+		meta = item.Meta.attrs()
+	}
+	for _, attr := range meta {
+		attrs += fmt.Sprintf(" %s='%s'", attr[0], xmlAttr(attr[1]))
+	}
+	switch format {
+	case "md":
+		info := mdInfoPath(item.Path)
+		if item.LineNumbers {
+			info += " line_numbers"
+		}
+		if item.Binary != "" {
+			info += " binary=" + item.Binary
+		}
+		for _, name := range item.Transforms {
+			info += " " + name
+		}
+		for _, attr := range meta {
+			info += " " + attr[0] + "=" + attr[1]
+		}
+		fence := mdFence(item.Content)
+		content := item.Content
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			// the closing fence must start a line of its own
+			content += "\n"
+		}
+		out := fmt.Sprintf("%s%s\n%s%s\n", fence, info, content, fence)
+		if item.Diff != "" {
+			fence = mdFence(item.Diff)
+			out += fmt.Sprintf("%sdiff\n%s%s\n", fence, item.Diff, fence)
+		}
+		return out
+	case "xml-strict":
+		attr := "path"
+		if isURL(item.Path) {
+			attr = "url"
+		}
+		path := xmlAttr(item.Path)
+		out := fmt.Sprintf("<%s %s='%s'%s>\n%s\n</%s>\n", tag, attr, path, attrs, cdata(item.Content), tag)
+		if item.Diff != "" {
+			out += fmt.Sprintf("<diff path='%s'>\n%s\n</diff>\n", path, cdata(item.Diff))
+		}
+		return out
+	default:
+		if isURL(item.Path) {
+			return fmt.Sprintf("<%s url='%s'>\n%s</%s>\n", tag, item.Path, item.Content, tag)
+		}
+		out := fmt.Sprintf("<%s path='%s'%s>\n%s</%s>\n", tag, item.Path, attrs, item.Content, tag)
+		if item.Diff != "" {
+			out += fmt.Sprintf("<diff path='%s'>\n%s</diff>\n", item.Path, item.Diff)
+		}
+		return out
+	}
+}
+
+// formatRemovedList lists the files removed since the snapshot, one per line.
+func formatRemovedList(list RemovedList, format string) string {
+	paths := strings.Join(list.Paths, "\n") + "\n"
+	switch format {
+	case "md":
+		fence := mdFence(paths)
+		return fmt.Sprintf("%sremoved\n%s%s\n", fence, paths, fence)
+	case "xml-strict":
+		return fmt.Sprintf("<removed>\n%s\n</removed>\n", cdata(paths))
+	default:
+		return fmt.Sprintf("<removed>\n%s</removed>\n", paths)
+	}
+}
+
+func formatTmuxItem(item TmuxPaneItem, format string) string {
+	switch format {
+	case "md":
+		header := fmt.Sprintf("# tmux-pane: id='%s' session='%s' window='%s' pane='%s'\n\n",
+			item.ID, item.Session, item.Window, item.Pane)
+		fence := mdFence(header, item.Content)
+		return fmt.Sprintf("%sshell\n%s%s%s\n", fence, header, item.Content, fence)
+	case "xml-strict":
+		e := xmlAttr
+		return fmt.Sprintf("<tmux_pane id='%s' session='%s' window='%s' pane='%s'>\n%s\n</tmux_pane>\n",
+			e(item.ID), e(item.Session), e(item.Window), e(item.Pane), cdata(item.Content))
+	default:
+		return fmt.Sprintf("<tmux_pane id='%s' session='%s' window='%s' pane='%s'>\n%s</tmux_pane>\n",
+			item.ID, item.Session, item.Window, item.Pane, item.Content)
+	}
+}
+
+func writeContents(w io.Writer, contents []string) error {
+	for _, c := range contents {
+		// treat snippet as raw text NOT a format string (Fprintf)
+		if _, err := io.WriteString(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTreeNode(node *TreeNode, prefix string, isLast bool) string {
+	var result strings.Builder
+
+	if node.Name != "." {
+		if isLast {
+			result.WriteString(prefix + "└── " + node.Name + "\n")
+		} else {
+			result.WriteString(prefix + "├── " + node.Name + "\n")
+		}
+	}
+
+	for i, child := range node.Children {
+		childIsLast := i == len(node.Children)-1
+		var childPrefix string
+		if node.Name == "." {
+			childPrefix = prefix
+		} else if isLast {
+			childPrefix = prefix + "    "
+		} else {
+			childPrefix = prefix + "│   "
+		}
+		result.WriteString(formatTreeNode(child, childPrefix, childIsLast))
+	}
+
+	return result.String()
+}
+
+func formatTreeOutput(tree *TreeNode, format string) string {
+	treeStr := formatTreeNode(tree, "", true)
+	switch format {
+	case "md":
+		fence := mdFence(treeStr)
+		return fmt.Sprintf("%stree\n%s%s\n\n", fence, treeStr, fence)
+	case "xml-strict":
+		return fmt.Sprintf("<tree path='%s'>\n%s\n</tree>\n", xmlAttr(tree.Path), cdata(treeStr))
+	default:
+		return fmt.Sprintf("<tree path='%s'>\n%s</tree>\n", tree.Path, treeStr)
+	}
+}
@@ -0,0 +1,292 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBody caps the options body of POST /dump.
+const maxRequestBody = 1 << 20
+
+// resolveRoots returns roots as absolute paths with their symlinks resolved.
+func resolveRoots(roots []string) ([]string, error) {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root %q: %w", root, err)
+		}
+		target, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root %q: %w", root, err)
+		}
+		resolved = append(resolved, target)
+	}
+	return resolved, nil
+}
+
+// withinRoots reports whether path, with its symlinks resolved, is one of roots
+// (resolved as by resolveRoots) or lies beneath one.
+func withinRoots(roots []string, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	target, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, target)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether Roots let the dump read path.
+func (e *Env) allowed(path string) bool {
+	return e.roots == nil || withinRoots(e.roots, path)
+}
+
+// ServerConfig configures the HTTP API of NewHandler.
+type ServerConfig struct {
+	// Token is the bearer token every request must carry; empty disables the check.
+	Token string
+	// Roots are the directories requests may read; at least one is required. The
+	// dirs of a request are relative to the first, which is also the default.
+	Roots []string
+}
+
+// NewHandler returns the HTTP API of `dump serve`:
+//
+//	POST /dump  dumps directories; the body is a JSON object of options (the
+//	            arguments of the dump_directory MCP tool) and the response is the
+//	            dump in its out_fmt
+//	GET  /list  lists the files a dump would include, one path per line
+//	GET  /tree  renders the directory tree
+//
+// GET endpoints take their options as query parameters, repeated for lists. Each
+// request starts from base and is cancelled when its client goes away. Lines the
+// dump reports on stderr, such as skipped files, come back as X-Dump-Warning
+// headers.
+func NewHandler(base Options, cfg ServerConfig) (http.Handler, error) {
+	if len(cfg.Roots) == 0 {
+		return nil, errors.New("at least one root directory is required")
+	}
+	roots, err := resolveRoots(cfg.Roots)
+	if err != nil {
+		return nil, err
+	}
+	// requests choose their own directories and cannot reach other inputs
+	base.Stdin = strings.NewReader("")
+	base.Roots = roots
+	s := &httpServer{base: base, token: cfg.Token, roots: roots}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dump", s.handle(func() toolArgs { return &dumpArgs{} }, false))
+	mux.HandleFunc("GET /list", s.handle(func() toolArgs { return &listArgs{} }, false))
+	mux.HandleFunc("GET /tree", s.handle(func() toolArgs { return &treeArgs{} }, true))
+	return s.authorize(mux), nil
+}
+
+type httpServer struct {
+	base  Options
+	token string
+	roots []string
+}
+
+// authorize rejects requests without the bearer token.
+func (s *httpServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dump"`)
+				http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handle returns the handler of an endpoint whose options decode into newArgs.
+func (s *httpServer) handle(newArgs func() toolArgs, tree bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		args := newArgs()
+		if err := decodeRequestArgs(w, r, args); err != nil {
+			http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts := s.base
+		if err := args.apply(&opts); err != nil {
+			http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		dirs, err := s.resolveDirs(opts.Dirs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		opts.Dirs = dirs
+		if err := opts.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var out, stderr bytes.Buffer
+		opts.Stderr = &stderr
+		run := Run
+		if tree {
+			run = RunTree
+		}
+		if err := run(r.Context(), opts, &out); err != nil {
+			if r.Context().Err() != nil {
+				// the client has gone; nobody reads the response
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		addWarnings(w.Header(), &stderr)
+		w.Header().Set("Content-Type", contentType(opts))
+		_, _ = w.Write(out.Bytes())
+	}
+}
+
+// maxWarningHeaders caps the X-Dump-Warning headers of a response, since
+// proxies and clients limit the size of the headers they accept.
+const maxWarningHeaders = 20
+
+// addWarnings adds the lines of stderr to h as X-Dump-Warning headers. Lines
+// past maxWarningHeaders are only counted, in X-Dump-Warnings-Omitted.
+func addWarnings(h http.Header, stderr io.Reader) {
+	added, omitted := 0, 0
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case added < maxWarningHeaders:
+			h.Add("X-Dump-Warning", line)
+			added++
+		default:
+			omitted++
+		}
+	}
+	if omitted > 0 {
+		h.Set("X-Dump-Warnings-Omitted", strconv.Itoa(omitted))
+	}
+}
+
+// resolveDirs makes the directories of a request absolute, relative to the
+// first root, and checks that the roots allow them.
+func (s *httpServer) resolveDirs(dirs []string) ([]string, error) {
+	if len(dirs) == 0 {
+		return []string{s.roots[0]}, nil
+	}
+	resolved := make([]string, len(dirs))
+	for i, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(s.roots[0], dir)
+		}
+		if !withinRoots(s.roots, dir) {
+			return nil, fmt.Errorf("directory %q is outside the allowed roots", dirs[i])
+		}
+		resolved[i] = dir
+	}
+	return resolved, nil
+}
+
+// decodeRequestArgs reads the options of r into args: the JSON body of a POST,
+// or the query parameters of a GET. Unknown options are an error.
+func decodeRequestArgs(w http.ResponseWriter, r *http.Request, args toolArgs) error {
+	var data []byte
+	if r.Method == http.MethodPost {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		if err != nil {
+			return err
+		}
+		data = body
+	} else {
+		var err error
+		if data, err = queryJSON(r.URL.Query(), reflect.TypeOf(args).Elem()); err != nil {
+			return err
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(args)
+}
+
+// queryJSON converts query parameters to the JSON object of an arguments struct,
+// using the property types of its schema.
+func queryJSON(q url.Values, t reflect.Type) ([]byte, error) {
+	props := argsSchema(t)["properties"].(map[string]any)
+	obj := make(map[string]any, len(q))
+	for name, values := range q {
+		kind := ""
+		if prop, ok := props[name].(map[string]any); ok {
+			kind, _ = prop["type"].(string)
+		}
+		last := values[len(values)-1]
+		switch kind {
+		case "array":
+			obj[name] = values
+		case "boolean":
+			// a bare ?tree means true
+			if last == "" {
+				obj[name] = true
+				continue
+			}
+			b, err := strconv.ParseBool(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q (must be true or false)", name, last)
+			}
+			obj[name] = b
+		case "integer":
+			n, err := strconv.Atoi(last)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q (must be an integer)", name, last)
+			}
+			obj[name] = n
+		default:
+			obj[name] = last
+		}
+	}
+	return json.Marshal(obj)
+}
+
+// contentType is the media type of the output of a run with opts.
+func contentType(opts Options) string {
+	if opts.List {
+		return "text/plain; charset=utf-8"
+	}
+	switch opts.Format {
+	case "md":
+		return "text/markdown; charset=utf-8"
+	case "json":
+		return "application/json"
+	case "jsonl":
+		return "application/x-ndjson"
+	default:
+		return "application/xml; charset=utf-8"
+	}
+}
@@ -0,0 +1,224 @@
+package dump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithinRoots(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := resolveRoots([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{root, true},
+		{filepath.Join(root, "sub"), true},
+		{filepath.Join(root, "sub", ".."), true},
+		{filepath.Join(root, ".."), false},
+		{outside, false},
+		{filepath.Join(root, "link"), false},
+		{root + "-sibling", false},
+		{filepath.Join(root, "missing"), false},
+	}
+	for _, tc := range testCases {
+		if got := withinRoots(roots, tc.path); got != tc.expected {
+			t.Errorf("withinRoots(%q) = %v, expected %v", tc.path, got, tc.expected)
+		}
+	}
+}
+
+func TestRunRootsSkipsSymlinkEscapes(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("hunter2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(root, "secret.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Dirs = []string{root}
+	opts.Roots = []string{root}
+	var stdout, stderr strings.Builder
+	opts.Stderr = &stderr
+	if err := Run(context.Background(), opts, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stdout.String(), "hunter2") || !strings.Contains(stdout.String(), "root/a.txt") {
+		t.Errorf("Unexpected dump:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "root/secret.txt: outside the allowed roots") {
+		t.Errorf("Expected a skip for the symlink, got %q", stderr.String())
+	}
+
+	opts.Dirs = []string{dir}
+	if err := Run(context.Background(), opts, io.Discard); err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+		t.Errorf("Expected an error for a directory outside the roots, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":       "package main\n",
+		"pkg/a.go":      "package pkg\n",
+		"docs/guide.md": "# guide\n",
+		"go.sum":        "example.com v1.0.0 h1:abc\n",
+	}
+	writeFiles(t, root, files)
+	base := filepath.Base(root)
+
+	handler, err := NewHandler(DefaultOptions(), ServerConfig{Token: "secret", Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	do := func(method, target, token, body string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+target, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(data)
+	}
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		token       string
+		body        string
+		status      int
+		contentType string
+		expected    string // contained in the body
+	}{
+		{"No token", "GET", "/list", "", "", http.StatusUnauthorized, "", "bearer token"},
+		{"Wrong token", "GET", "/list", "nope", "", http.StatusUnauthorized, "", "bearer token"},
+		{"List", "GET", "/list?ext=go", "secret", "", http.StatusOK, "text/plain; charset=utf-8",
+			filepath.Join(base, "main.go") + "\n" + filepath.Join(base, "pkg", "a.go") + "\n"},
+		{"List relative dir", "GET", "/list?dir=pkg", "secret", "", http.StatusOK, "text/plain; charset=utf-8", "pkg/a.go\n"},
+		{"List bare boolean", "GET", "/list?include_generated&glob=*.sum", "secret", "", http.StatusOK, "", "go.sum"},
+		{"Tree", "GET", "/tree?out_fmt=md", "secret", "", http.StatusOK, "text/markdown; charset=utf-8", "```tree\n└── " + base},
+		{"Dump md", "POST", "/dump", "secret", `{"dir":["docs"],"out_fmt":"md"}`, http.StatusOK, "text/markdown; charset=utf-8",
+			"```docs/guide.md\n# guide\n```"},
+		{"Dump json", "POST", "/dump", "secret", `{"ext":["md"],"out_fmt":"json"}`, http.StatusOK, "application/json", `"content": "# guide\n"`},
+		{"Dump default xml", "POST", "/dump", "secret", "", http.StatusOK, "application/xml; charset=utf-8", "<document path='" + base + "/main.go'>"},
+		{"Outside the roots", "POST", "/dump", "secret", `{"dir":["../"]}`, http.StatusForbidden, "", "outside the allowed roots"},
+		{"Absolute dir outside the roots", "GET", "/list?dir=/", "secret", "", http.StatusForbidden, "", "outside the allowed roots"},
+		{"Unknown option", "POST", "/dump", "secret", `{"cmd":["id"]}`, http.StatusBadRequest, "", `unknown field "cmd"`},
+		{"Invalid boolean", "GET", "/list?include_generated=maybe", "secret", "", http.StatusBadRequest, "", "must be true or false"},
+		{"Invalid format", "POST", "/dump", "secret", `{"out_fmt":"yaml"}`, http.StatusBadRequest, "", "yaml"},
+		{"Wrong method", "GET", "/dump", "secret", "", http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := do(tc.method, tc.target, tc.token, tc.body)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d, expected %d (body %q)", resp.StatusCode, tc.status, body)
+			}
+			if tc.contentType != "" && resp.Header.Get("Content-Type") != tc.contentType {
+				t.Errorf("Content-Type = %q, expected %q", resp.Header.Get("Content-Type"), tc.contentType)
+			}
+			if !strings.Contains(body, tc.expected) {
+				t.Errorf("body = %q, expected it to contain %q", body, tc.expected)
+			}
+		})
+	}
+
+	// the skipped lock file is reported in a header
+	resp, _ := do("GET", "/list", "secret", "")
+	if got := resp.Header.Values("X-Dump-Warning"); len(got) != 2 || got[1] != filepath.Join(base, "go.sum")+": lock file" {
+		t.Errorf("X-Dump-Warning = %q", got)
+	}
+}
+
+func TestAddWarnings(t *testing.T) {
+	var stderr strings.Builder
+	stderr.WriteString("skipped 300 files:\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&stderr, "  bin/%03d.bin: binary\n", i)
+	}
+	h := http.Header{}
+	addWarnings(h, strings.NewReader(stderr.String()))
+
+	got := h.Values("X-Dump-Warning")
+	if len(got) != maxWarningHeaders || got[0] != "skipped 300 files:" || got[1] != "bin/000.bin: binary" {
+		t.Errorf("X-Dump-Warning = %d headers starting %q", len(got), got[:min(len(got), 2)])
+	}
+	if omitted := h.Get("X-Dump-Warnings-Omitted"); omitted != "281" {
+		t.Errorf("X-Dump-Warnings-Omitted = %q, expected 281", omitted)
+	}
+}
+
+func TestHandlerCancelledRequest(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := NewHandler(DefaultOptions(), ServerConfig{Roots: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/dump", strings.NewReader("{}")).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	// the run stops with the request and writes nothing
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected no body for a cancelled request, got %q", rec.Body.String())
+	}
+}
+
+func TestNewHandlerRequiresRoots(t *testing.T) {
+	if _, err := NewHandler(DefaultOptions(), ServerConfig{}); err == nil {
+		t.Error("Expected an error without roots")
+	}
+	if _, err := NewHandler(DefaultOptions(), ServerConfig{Roots: []string{filepath.Join(t.TempDir(), "missing")}}); err == nil {
+		t.Error("Expected an error for a missing root")
+	}
+}
@@ -0,0 +1,138 @@
+package dump
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// snapshotVersion is the format of the file written by --snapshot.
+const snapshotVersion = 1
+
+// snapshot is the state --snapshot records after a run: the files the dump
+// carried, by path as shown in the dump.
+type snapshot struct {
+	Version int                      `json:"version"`
+	Files   map[string]snapshotEntry `json:"files"`
+}
+
+// snapshotEntry is a file as it was when it was last dumped.
+type snapshotEntry struct {
+	Hash string `json:"hash"` // sha256 of the content on disk
+	// Content is the content as written to the dump, after redaction, so
+	// --diff works without git; only recorded with --diff, never for binary files
+	Content string `json:"content,omitempty"`
+}
+
+// RemovedList names the files of the previous snapshot that are no longer part
+// of the dump (--since-snapshot).
+type RemovedList struct {
+	Paths []string
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{Version: snapshotVersion, Files: make(map[string]snapshotEntry)}
+}
+
+// loadSnapshot reads the snapshot at path. A missing file is an empty snapshot,
+// so the first run with --since-snapshot dumps everything.
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newSnapshot(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	s := newSnapshot()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s", s.Version, path)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]snapshotEntry)
+	}
+	return s, nil
+}
+
+// since drops the files of blocks that are unchanged since s was taken and
+// appends a block listing the files of s that were not collected. It returns the kept blocks and the
+// entries of s for files that are still there, which the next snapshot carries
+// over for files that end up not being written (unchanged, or cut by --max-tokens).
+func (s *snapshot) since(blocks []*Block) ([]*Block, *snapshot) {
+	carried := newSnapshot()
+	var kept []*Block
+	for _, b := range blocks {
+		if b.Item == nil || b.Item.state == nil {
+			kept = append(kept, b)
+			continue
+		}
+		prev, ok := s.Files[b.Item.Path]
+		if ok {
+			carried.Files[b.Item.Path] = prev
+			if prev.Hash == b.Item.state.Hash {
+				continue
+			}
+		}
+		kept = append(kept, b)
+	}
+
+	var removed []string
+	for path := range s.Files {
+		if _, ok := carried.Files[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		kept = append(kept, &Block{Removed: &RemovedList{Paths: removed}})
+	}
+	return kept, carried
+}
+
+// diff adds the diff against the content recorded in s to the changed files of
+// blocks. It runs after redaction, so both sides are as they were dumped; files
+// recorded without content get none.
+func (s *snapshot) diff(blocks []*Block) {
+	for _, b := range blocks {
+		if b.Item == nil || b.Item.state == nil || b.Item.Binary != "" {
+			continue
+		}
+		if prev, ok := s.Files[b.Item.Path]; ok && prev.Content != "" {
+			b.Item.Diff = unifiedDiff("a/"+b.Item.Path, "b/"+b.Item.Path, prev.Content, b.Item.Content)
+		}
+	}
+}
+
+// record adds the files written whole in blocks to s, with the content they
+// were written with when content is set. Files in truncated keep the entry s
+// carried over for them, if any.
+func (s *snapshot) record(blocks []*Block, truncated map[*Block]bool, content bool) {
+	for _, b := range blocks {
+		if b.Item == nil || b.Item.state == nil || truncated[b] {
+			continue
+		}
+		entry := *b.Item.state
+		if content && b.Item.Binary == "" {
+			entry.Content = b.Item.Content
+		}
+		s.Files[b.Item.Path] = entry
+	}
+}
+
+// save writes s to path; a nil snapshot writes nothing.
+func (s *snapshot) save(path string) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
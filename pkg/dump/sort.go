@@ -0,0 +1,139 @@
+package dump
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortModes are the accepted --sort values.
+var sortModes = map[string]struct{}{
+	"path":        {},
+	"size":        {},
+	"mtime":       {},
+	"extension":   {},
+	"git-recency": {},
+}
+
+// sortItems orders the file items of one source. path sorts by path component,
+// size puts the smallest files first, mtime and git-recency put the most recently
+// modified or committed files first, and extension groups files by extension.
+// Ties are broken by path so the order never depends on the file system.
+// gitDir is where git is asked for commit times.
+func sortItems(items []*Item, mode, gitDir string) error {
+	if mode == "" || len(items) == 0 {
+		return nil
+	}
+
+	var keys map[*Item]int64
+	switch mode {
+	case "size", "mtime":
+		keys = make(map[*Item]int64, len(items))
+		for _, item := range items {
+			info, err := os.Stat(item.file)
+			if err != nil {
+				continue
+			}
+			if mode == "size" {
+				keys[item] = info.Size()
+			} else {
+				// negate so the newest file sorts first
+				keys[item] = -info.ModTime().UnixNano()
+			}
+		}
+	case "git-recency":
+		times, err := gitCommitTimes(gitDir, items)
+		if err != nil {
+			return err
+		}
+		keys = make(map[*Item]int64, len(items))
+		for _, item := range items {
+			keys[item] = -times[item]
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		switch mode {
+		case "extension":
+			ea, eb := strings.ToLower(filepath.Ext(a.Path)), strings.ToLower(filepath.Ext(b.Path))
+			if ea != eb {
+				return ea < eb
+			}
+		case "size", "mtime", "git-recency":
+			if keys[a] != keys[b] {
+				return keys[a] < keys[b]
+			}
+		}
+		return comparePaths(a.Path, b.Path) < 0
+	})
+	return nil
+}
+
+// comparePaths compares paths component by component, which matches the order
+// of a directory walk ("a/b" sorts before "a-c").
+func comparePaths(a, b string) int {
+	pa := strings.Split(filepath.ToSlash(a), "/")
+	pb := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if c := strings.Compare(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// gitCommitTimes returns the time of the last commit touching each item's file,
+// as Unix seconds. Files that were never committed get the newest possible time.
+func gitCommitTimes(dir string, items []*Item) (map[*Item]int64, error) {
+	top, err := runCmd("git", "-C", dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("--sort git-recency: %s is not a git repository: %w", dir, err)
+	}
+	// paths are relative to the top level; "." limits the log to dir
+	out, err := runCmd("git", "-C", dir, "log", "--format=%x01%ct", "--name-only", "-z", "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("git log failed in %s: %w", dir, err)
+	}
+
+	// the log is newest first, so the first time seen for a path is its last commit
+	last := make(map[string]int64)
+	var ts int64
+	for _, field := range strings.Split(out, "\x00") {
+		field = strings.TrimPrefix(field, "\n")
+		if strings.HasPrefix(field, "\x01") {
+			ts, _ = strconv.ParseInt(field[1:], 10, 64)
+			continue
+		}
+		if _, ok := last[field]; field != "" && !ok {
+			last[field] = ts
+		}
+	}
+
+	if resolved, err := filepath.EvalSymlinks(top); err == nil {
+		top = resolved
+	}
+	times := make(map[*Item]int64, len(items))
+	for _, item := range items {
+		times[item] = math.MaxInt64
+		abs, err := filepath.Abs(item.file)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		rel, err := filepath.Rel(top, abs)
+		if err != nil {
+			continue
+		}
+		if t, ok := last[filepath.ToSlash(rel)]; ok {
+			times[item] = t
+		}
+	}
+	return times, nil
+}
@@ -0,0 +1,127 @@
+package dump
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func itemPaths(items []*Item) []string {
+	var paths []string
+	for _, item := range items {
+		paths = append(paths, item.Path)
+	}
+	return paths
+}
+
+func TestComparePaths(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"a/b.go", "a-c.go", -1},
+		{"a.go", "a.go", 0},
+		{"b.go", "a/z.go", 1},
+		{"a", "a/b", -1},
+	}
+	for _, tc := range testCases {
+		got := comparePaths(tc.a, tc.b)
+		if (got < 0) != (tc.expected < 0) || (got > 0) != (tc.expected > 0) {
+			t.Errorf("comparePaths(%q, %q) = %d, expected sign of %d", tc.a, tc.b, got, tc.expected)
+		}
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"b.md", "12345", 3 * time.Hour},
+		{"a/z.go", "1", 2 * time.Hour},
+		{"a-c.go", "123", time.Hour},
+		{"c.MD", "12", 4 * time.Hour},
+	}
+	now := time.Now()
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newItems := func() []*Item {
+		var items []*Item
+		for _, f := range files {
+			items = append(items, &Item{Path: f.name, file: filepath.Join(dir, f.name)})
+		}
+		return items
+	}
+
+	testCases := []struct {
+		mode     string
+		expected []string
+	}{
+		{"", []string{"b.md", "a/z.go", "a-c.go", "c.MD"}},
+		{"path", []string{"a/z.go", "a-c.go", "b.md", "c.MD"}},
+		{"size", []string{"a/z.go", "c.MD", "a-c.go", "b.md"}},
+		{"mtime", []string{"a-c.go", "a/z.go", "b.md", "c.MD"}},
+		{"extension", []string{"a/z.go", "a-c.go", "b.md", "c.MD"}},
+	}
+	for _, tc := range testCases {
+		t.Run("Sort by "+tc.mode, func(t *testing.T) {
+			items := newItems()
+			if err := sortItems(items, tc.mode, dir); err != nil {
+				t.Fatalf("sortItems: %v", err)
+			}
+			if got := itemPaths(items); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("sortItems(%q) = %q, expected %q", tc.mode, got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("git-recency outside a repository", func(t *testing.T) {
+		if err := sortItems(newItems(), "git-recency", dir); err == nil {
+			t.Error("Expected an error outside a git repository")
+		}
+	})
+}
+
+func TestSortItemsGitRecency(t *testing.T) {
+	dir := initGitRepo(t, map[string]string{"old.go": "package old\n", "sub/mid.go": "package sub\n"})
+	if err := os.WriteFile(filepath.Join(dir, "sub", "mid.go"), []byte("package sub\n\nvar x int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// commit the change with a later committer date than the initial commit
+	cmd := exec.Command("git", "-C", dir, "-c", "user.Name=test", "-c", "user.email=test@example.com",
+		"commit", "-q", "-a", "-m", "later")
+	cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2099-01-01T00:00:00Z")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v: %s", err, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []*Item
+	for _, name := range []string{"old.go", "sub/mid.go", "new.go"} {
+		items = append(items, &Item{Path: name, file: filepath.Join(dir, name)})
+	}
+	if err := sortItems(items, "git-recency", dir); err != nil {
+		t.Fatalf("sortItems: %v", err)
+	}
+	expected := []string{"new.go", "sub/mid.go", "old.go"}
+	if got := itemPaths(items); !reflect.DeepEqual(got, expected) {
+		t.Errorf("sortItems(git-recency) = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,305 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// manifestName is the file written next to the parts of a split dump.
+const manifestName = "manifest.json"
+
+// Markers written where an oversized block is split between parts.
+const (
+	continuesMarker  = "... [continued in the next part] ...\n"
+	continuedMarker  = "... [continued from the previous part] ...\n"
+	maxPartsEstimate = 99999 // sizes the part header before the part count is known
+)
+
+// partRenderer is implemented by renderers that mark the parts of a split dump
+// themselves; other renderers get an html comment header.
+type partRenderer interface {
+	renderPart(w io.Writer, blocks []*Block, part, parts int) error
+}
+
+func (r textRenderer) renderPart(w io.Writer, blocks []*Block, part, parts int) error {
+	header := fmt.Sprintf("<!-- part %d of %d -->\n", part, parts)
+	if r.format == "jsonl" {
+		header = marshalJSON(struct {
+			Kind  string `json:"kind"`
+			Part  int    `json:"part"`
+			Parts int    `json:"parts"`
+		}{"part", part, parts}, "")
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	return r.Render(w, blocks)
+}
+
+func (r jsonRenderer) renderPart(w io.Writer, blocks []*Block, part, parts int) error {
+	doc := newJSONDocument(blocks, r.version)
+	doc.Metadata.Part = part
+	doc.Metadata.Parts = parts
+	_, err := io.WriteString(w, marshalJSON(doc, "  "))
+	return err
+}
+
+// renderPart writes one part of a split dump: a "part i of n" header, then the blocks.
+func renderPart(w io.Writer, r Renderer, blocks []*Block, part, parts int) error {
+	if pr, ok := r.(partRenderer); ok {
+		return pr.renderPart(w, blocks, part, parts)
+	}
+	if _, err := fmt.Fprintf(w, "<!-- part %d of %d -->\n", part, parts); err != nil {
+		return err
+	}
+	return r.Render(w, blocks)
+}
+
+// splitEntry is a block placed in a part; pieces > 0 when it is one piece of an
+// oversized block.
+type splitEntry struct {
+	block         *Block
+	piece, pieces int
+}
+
+// splitter partitions rendered blocks into parts of at most limit units, as
+// counted by measure (tokens or bytes).
+type splitter struct {
+	limit   int
+	measure func(string) int
+	r       Renderer
+}
+
+// overhead is the size of an empty part: its header and, for json, the document.
+func (s *splitter) overhead() int {
+	var buf bytes.Buffer
+	_ = renderPart(&buf, s.r, nil, maxPartsEstimate, maxPartsEstimate)
+	return s.measure(buf.String())
+}
+
+// split packs blocks into parts in output order. A block only starts a new part
+// when it does not fit in the current one; a block larger than a whole part is
+// cut on line boundaries into pieces that each fill a part.
+func (s *splitter) split(blocks []*Block) ([][]splitEntry, error) {
+	room := s.limit - s.overhead()
+	if room <= 0 {
+		return nil, fmt.Errorf("split budget of %d is too small for the part header", s.limit)
+	}
+
+	var parts [][]splitEntry
+	var cur []splitEntry
+	used := 0
+	add := func(e splitEntry, size int) {
+		if len(cur) > 0 && used+size > room {
+			parts = append(parts, cur)
+			cur, used = nil, 0
+		}
+		cur = append(cur, e)
+		used += size
+	}
+	for _, b := range blocks {
+		size := s.measure(s.r.RenderBlock(b))
+		if size <= room {
+			add(splitEntry{block: b}, size)
+			continue
+		}
+		pieces := s.splitBlock(b, room)
+		if len(pieces) == 1 {
+			// trees and single-line blocks cannot be cut; they get a part of their own
+			add(splitEntry{block: b}, size)
+			continue
+		}
+		for i, p := range pieces {
+			add(splitEntry{block: p, piece: i + 1, pieces: len(pieces)}, s.measure(s.r.RenderBlock(p)))
+		}
+	}
+	if len(cur) > 0 {
+		parts = append(parts, cur)
+	}
+	return parts, nil
+}
+
+// splitBlock cuts b's content on line boundaries into pieces whose rendered form
+// fits in room, adding continuation markers where it was cut. A line longer than
+// room is kept whole. Trees come back as a single block.
+func (s *splitter) splitBlock(b *Block, room int) []*Block {
+	content := b.body()
+	if content == nil {
+		return []*Block{b}
+	}
+	lines := strings.SplitAfter(*content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	piece := func(start, end int) *Block {
+		var sb strings.Builder
+		if start > 0 {
+			sb.WriteString(continuedMarker)
+		}
+		sb.WriteString(strings.Join(lines[start:end], ""))
+		if end < len(lines) {
+			sb.WriteString(continuesMarker)
+		}
+		return b.withBody(sb.String(), end == len(lines))
+	}
+
+	var pieces []*Block
+	for start := 0; start < len(lines); {
+		// estimate how many lines fit from per-line sizes, then back off until the piece does
+		available := room - s.measure(s.r.RenderBlock(piece(start, start)))
+		end := start
+		for end < len(lines) {
+			n := s.measure(lines[end])
+			if n > available {
+				break
+			}
+			available -= n
+			end++
+		}
+		for end > start+1 && s.measure(s.r.RenderBlock(piece(start, end))) > room {
+			end--
+		}
+		if end == start {
+			end++
+		}
+		pieces = append(pieces, piece(start, end))
+		start = end
+	}
+	return pieces
+}
+
+// withBody returns a copy of b with its content replaced by body. The diff of a
+// file and the stderr of a command are kept only on the last piece.
+func (b *Block) withBody(body string, last bool) *Block {
+	nb := *b
+	switch {
+	case b.Tmux != nil:
+		tmux := *b.Tmux
+		tmux.Content = body
+		nb.Tmux = &tmux
+	case b.Command != nil:
+		cmd := *b.Command
+		cmd.Stdout = body
+		if !last {
+			cmd.Stderr = ""
+		}
+		nb.Command = &cmd
+	default:
+		item := *b.Item
+		item.Content = body
+		if !last {
+			item.Diff = ""
+		}
+		nb.Item = &item
+	}
+	return &nb
+}
+
+// splitManifest records which blocks landed in which part file.
+type splitManifest struct {
+	Unit  string         `json:"unit"`
+	Limit int            `json:"limit"`
+	Parts []manifestPart `json:"parts"`
+}
+
+type manifestPart struct {
+	Part  int            `json:"part"`
+	File  string         `json:"file"`
+	Size  int            `json:"size"`
+	Items []manifestItem `json:"items"`
+}
+
+type manifestItem struct {
+	Kind   string `json:"kind"`
+	Label  string `json:"label"`
+	Piece  int    `json:"piece,omitempty"`
+	Pieces int    `json:"pieces,omitempty"`
+}
+
+// partFileRgx matches part files written by a previous split into the same directory.
+var partFileRgx = regexp.MustCompile(`^part-[0-9]{3,}\.(xml|md|json|jsonl|txt)$`)
+
+// writeParts renders parts into dir as part-001.ext, part-002.ext, ... and writes
+// the manifest. Part files left over from an earlier, longer split are removed.
+func (s *splitter) writeParts(dir, ext, unit string, parts [][]splitEntry) (*splitManifest, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	manifest := &splitManifest{Unit: unit, Limit: s.limit, Parts: []manifestPart{}}
+	written := make(map[string]bool)
+	for i, entries := range parts {
+		blocks := make([]*Block, len(entries))
+		mp := manifestPart{Part: i + 1, File: fmt.Sprintf("part-%03d.%s", i+1, ext)}
+		for j, e := range entries {
+			blocks[j] = e.block
+			mp.Items = append(mp.Items, manifestItem{
+				Kind:   e.block.Kind(),
+				Label:  e.block.Label(),
+				Piece:  e.piece,
+				Pieces: e.pieces,
+			})
+		}
+
+		var buf bytes.Buffer
+		if err := renderPart(&buf, s.r, blocks, i+1, len(parts)); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, mp.File), buf.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", mp.File, err)
+		}
+		mp.Size = s.measure(buf.String())
+		written[mp.File] = true
+		manifest.Parts = append(manifest.Parts, mp)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() && partFileRgx.MatchString(e.Name()) && !written[e.Name()] {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return nil, fmt.Errorf("failed to remove stale %s: %w", e.Name(), err)
+			}
+		}
+	}
+
+	data := marshalJSON(manifest, "  ")
+	if err := os.WriteFile(filepath.Join(dir, manifestName), []byte(data), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", manifestName, err)
+	}
+	return manifest, nil
+}
+
+// partExtension returns the part file extension for an output format.
+func partExtension(format string, custom bool) string {
+	switch {
+	case custom:
+		return "txt"
+	case format == "xml-strict":
+		return "xml"
+	default:
+		return format
+	}
+}
+
+// formatSplitReport summarizes the parts written by a split dump.
+func formatSplitReport(dir string, m *splitManifest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "wrote %d parts to %s (%s)\n", len(m.Parts), dir, manifestName)
+	for _, p := range m.Parts {
+		fmt.Fprintf(&sb, "  %s: %d %s, %d items", p.File, p.Size, m.Unit, len(p.Items))
+		if p.Size > m.Limit {
+			sb.WriteString(" (over budget: the item cannot be split further)")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,209 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitParts(t *testing.T) {
+	var big strings.Builder
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&big, "line %02d of the big file\n", i)
+	}
+	blocks := []*Block{
+		{Item: &Item{Path: "a.go", Content: strings.Repeat("a", 100) + "\n"}},
+		{Item: &Item{Path: "b.go", Content: strings.Repeat("b", 100) + "\n"}},
+		{Item: &Item{Path: "big.go", Content: big.String(), Diff: "+line 40\n"}},
+		{Tree: &TreeNode{Name: ".", Path: "."}},
+	}
+	s := &splitter{limit: 300, measure: func(text string) int { return len(text) }, r: textRenderer{format: "xml", tag: "document"}}
+	room := s.limit - s.overhead()
+
+	parts, err := s.split(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels [][]string
+	var pieces []*Block
+	for _, entries := range parts {
+		var part []string
+		size := 0
+		for _, e := range entries {
+			part = append(part, e.block.Label())
+			size += len(s.r.RenderBlock(e.block))
+			if e.pieces > 0 {
+				pieces = append(pieces, e.block)
+			}
+		}
+		if size > room {
+			t.Errorf("Part %q is %d bytes, over the room of %d", part, size, room)
+		}
+		labels = append(labels, part)
+	}
+
+	// a.go and b.go together exceed the room, so b.go starts a new part whole
+	if len(labels) < 4 || labels[0][0] != "a.go" || len(labels[0]) != 1 || labels[1][0] != "b.go" {
+		t.Fatalf("Unexpected parts %q", labels)
+	}
+	if len(pieces) < 2 {
+		t.Fatalf("Expected big.go to be split into pieces, got %d", len(pieces))
+	}
+
+	var rejoined strings.Builder
+	for i, p := range pieces {
+		content := p.Item.Content
+		if i > 0 && !strings.HasPrefix(content, continuedMarker) {
+			t.Errorf("Piece %d does not start with the continued marker: %q", i+1, content)
+		}
+		if i < len(pieces)-1 {
+			if !strings.HasSuffix(content, continuesMarker) {
+				t.Errorf("Piece %d does not end with the continues marker: %q", i+1, content)
+			}
+			if p.Item.Diff != "" {
+				t.Errorf("Piece %d carries the diff, expected only the last piece to", i+1)
+			}
+		}
+		content = strings.TrimPrefix(content, continuedMarker)
+		rejoined.WriteString(strings.TrimSuffix(content, continuesMarker))
+	}
+	if rejoined.String() != big.String() {
+		t.Errorf("Pieces rejoin to %q, expected %q", rejoined.String(), big.String())
+	}
+	if pieces[len(pieces)-1].Item.Diff != "+line 40\n" {
+		t.Errorf("Expected the last piece to keep the diff")
+	}
+	if blocks[2].Item.Content != big.String() {
+		t.Errorf("split modified the original block")
+	}
+
+	// the tree shares the last piece's part if it fits
+	last := labels[len(labels)-1]
+	if last[len(last)-1] != "." {
+		t.Errorf("Expected the tree in the last part, got %q", labels)
+	}
+
+	t.Run("Budget smaller than the header", func(t *testing.T) {
+		small := &splitter{limit: 10, measure: s.measure, r: s.r}
+		if _, err := small.split(blocks); err == nil {
+			t.Error("Expected an error for a budget smaller than the part header")
+		}
+	})
+}
+
+func TestRunSplit(t *testing.T) {
+	src := &staticSource{items: []*Item{
+		{Path: "a.go", Content: strings.Repeat("a\n", 60)},
+		{Path: "b.go", Content: strings.Repeat("b\n", 60)},
+		{Path: "c.go", Content: "package c\n"},
+	}}
+
+	t.Run("Xml parts and manifest", func(t *testing.T) {
+		outDir := filepath.Join(t.TempDir(), "chunks")
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"part-009.xml", "notes.txt"} {
+			if err := os.WriteFile(filepath.Join(outDir, name), []byte("old\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		opts := DefaultOptions()
+		opts.Sources = []Source{src}
+		opts.SplitBytes = 200
+		opts.OutDir = outDir
+		var stderr, out bytes.Buffer
+		opts.Stderr = &stderr
+		if err := Run(context.Background(), opts, &out); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("Expected nothing on the writer, got %q", out.String())
+		}
+
+		data, err := os.ReadFile(filepath.Join(outDir, manifestName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest splitManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatalf("Invalid manifest: %v", err)
+		}
+		if manifest.Unit != "bytes" || manifest.Limit != 200 || len(manifest.Parts) != 3 {
+			t.Fatalf("Unexpected manifest %s", data)
+		}
+		for i, p := range manifest.Parts {
+			content, err := os.ReadFile(filepath.Join(outDir, p.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := fmt.Sprintf("<!-- part %d of 3 -->\n", i+1)
+			if !strings.HasPrefix(string(content), header) {
+				t.Errorf("%s does not start with %q", p.File, header)
+			}
+			if p.Size != len(content) || p.Size > 200 {
+				t.Errorf("%s: manifest size %d, file size %d, limit 200", p.File, p.Size, len(content))
+			}
+		}
+		if got := manifest.Parts[1].Items; len(got) != 1 || got[0].Label != "b.go" || got[0].Kind != "file" {
+			t.Errorf("Expected b.go alone in part 2, got %+v", got)
+		}
+
+		if _, err := os.Stat(filepath.Join(outDir, "part-009.xml")); !os.IsNotExist(err) {
+			t.Error("Expected the stale part file to be removed")
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "notes.txt")); err != nil {
+			t.Error("Expected unrelated files to be kept")
+		}
+		if !strings.Contains(stderr.String(), "wrote 3 parts to "+outDir) {
+			t.Errorf("Expected a split summary on stderr, got %q", stderr.String())
+		}
+	})
+
+	t.Run("Json parts carry part metadata", func(t *testing.T) {
+		outDir := t.TempDir()
+		opts := DefaultOptions()
+		opts.Sources = []Source{src}
+		opts.Format = "json"
+		opts.SplitTokens = 150
+		opts.Tokenizer = "chars"
+		opts.OutDir = outDir
+		opts.Stderr = &bytes.Buffer{}
+		if err := Run(context.Background(), opts, &bytes.Buffer{}); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "part-001.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc jsonDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("Invalid part document: %v", err)
+		}
+		if doc.Metadata.Part != 1 || doc.Metadata.Parts < 2 {
+			t.Errorf("Unexpected part metadata %+v", doc.Metadata)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, mod := range []func(*Options){
+			func(o *Options) { o.SplitTokens = 100 },
+			func(o *Options) { o.OutDir = "chunks" },
+			func(o *Options) { o.SplitTokens, o.SplitBytes, o.OutDir = 100, 100, "chunks" },
+			func(o *Options) { o.SplitBytes, o.OutDir = -1, "chunks" },
+		} {
+			opts := DefaultOptions()
+			opts.Sources = []Source{src}
+			mod(&opts)
+			if err := Run(context.Background(), opts, &bytes.Buffer{}); err == nil {
+				t.Errorf("Expected an error for %+v", opts)
+			}
+		}
+	})
+}
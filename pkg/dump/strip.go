@@ -0,0 +1,380 @@
+package dump
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// commentSyntax describes enough of a language's lexical structure to find its
+// comments without being fooled by comment markers inside strings.
+type commentSyntax struct {
+	line  []string  // line comment openers
+	block [2]string // block comment delimiters, if any
+	// nested block comments (Rust)
+	nested bool
+	// quotes are string delimiters with backslash escapes; raw delimiters have none
+	quotes string
+	raw    string
+	// multiline lists the delimiters whose strings may span lines
+	multiline string
+	// keep are comments kept verbatim because they change meaning (//go:embed)
+	keep []string
+
+	// hashWord: "#" starts a comment only at the start of a word (shell, YAML)
+	hashWord bool
+	// scalarQuotes: quotes only open a string at the start of a value (YAML)
+	scalarQuotes bool
+	// python: triple-quoted strings, and docstrings (string statements) are stripped
+	python bool
+	// rust: ' opens a char literal only when it closes (lifetimes do not), and r#"..."# strings
+	rust bool
+}
+
+var (
+	cSyntax  = &commentSyntax{line: []string{"//"}, block: [2]string{"/*", "*/"}, quotes: `"'`}
+	goSyntax = &commentSyntax{
+		line: []string{"//"}, block: [2]string{"/*", "*/"}, quotes: `"'`, raw: "`", multiline: "`",
+		keep: []string{"//go:", "//line ", "// +build", "//export "},
+	}
+	// template literals are multiline strings; regex literals are not recognized
+	jsSyntax     = &commentSyntax{line: []string{"//"}, block: [2]string{"/*", "*/"}, quotes: "\"'`", multiline: "`"}
+	rustSyntax   = &commentSyntax{line: []string{"//"}, block: [2]string{"/*", "*/"}, nested: true, quotes: `"`, multiline: `"`, rust: true}
+	pythonSyntax = &commentSyntax{line: []string{"#"}, quotes: `"'`, python: true}
+	shellSyntax  = &commentSyntax{line: []string{"#"}, quotes: `"`, raw: `'`, multiline: `"'`, hashWord: true}
+	// block scalars (| and >) are not recognized, so "#" lines inside them are stripped
+	yamlSyntax = &commentSyntax{line: []string{"#"}, quotes: `"`, raw: `'`, hashWord: true, scalarQuotes: true}
+	cssSyntax  = &commentSyntax{block: [2]string{"/*", "*/"}, quotes: `"'`}
+)
+
+// commentSyntaxes maps file extensions to their comment syntax.
+var commentSyntaxes = map[string]*commentSyntax{
+	".go": goSyntax,
+	".py": pythonSyntax, ".pyi": pythonSyntax,
+	".js": jsSyntax, ".jsx": jsSyntax, ".mjs": jsSyntax, ".cjs": jsSyntax,
+	".ts": jsSyntax, ".tsx": jsSyntax, ".mts": jsSyntax, ".cts": jsSyntax,
+	".rs": rustSyntax,
+	".c":  cSyntax, ".h": cSyntax, ".cc": cSyntax, ".cpp": cSyntax, ".cxx": cSyntax,
+	".hh": cSyntax, ".hpp": cSyntax, ".hxx": cSyntax, ".m": cSyntax, ".mm": cSyntax,
+	".java": cSyntax, ".cs": cSyntax, ".kt": cSyntax, ".kts": cSyntax, ".scala": cSyntax,
+	".swift": cSyntax, ".dart": cSyntax, ".proto": cSyntax,
+	".css": cssSyntax, ".scss": cSyntax, ".less": cSyntax,
+	".sh": shellSyntax, ".bash": shellSyntax, ".zsh": shellSyntax, ".ksh": shellSyntax,
+	".yaml": yamlSyntax, ".yml": yamlSyntax, ".toml": shellSyntax,
+}
+
+// stripCommentsContent is the contentTransformer for --strip-comments. Files in
+// languages it does not recognize are left alone.
+func stripCommentsContent(path string, lines []contentLine) ([]contentLine, error) {
+	cs, ok := commentSyntaxes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return lines, nil
+	}
+	texts, dropped := cs.strip(lineTexts(lines))
+	kept := lines[:0:0]
+	for i, text := range texts {
+		if !dropped[i] {
+			kept = append(kept, contentLine{num: lines[i].num, text: text})
+		}
+	}
+	return kept, nil
+}
+
+// strip removes the comments from src. It returns one entry per source line, and
+// whether that line held nothing but comments and should be dropped. Lines that
+// lost a trailing comment keep their code.
+func (cs *commentSyntax) strip(src string) (lines []string, dropped []bool) {
+	var cur strings.Builder
+	stripped := false  // the current line lost a comment
+	depth := 0         // bracket depth, for python docstrings
+	continued := false // the previous line ended with a backslash
+
+	endLine := func() {
+		line := cur.String()
+		if stripped {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		lines = append(lines, line)
+		dropped = append(dropped, stripped && strings.TrimSpace(line) == "")
+		cur.Reset()
+		stripped = false
+	}
+	// emit copies text to the output, ending lines at newlines
+	emit := func(text string) {
+		for {
+			j := strings.IndexByte(text, '\n')
+			if j < 0 {
+				cur.WriteString(text)
+				return
+			}
+			cur.WriteString(text[:j])
+			endLine()
+			text = text[j+1:]
+		}
+	}
+	// skip drops text, keeping its newlines as (dropped) lines
+	skip := func(text string) {
+		stripped = true
+		for range strings.Count(text, "\n") {
+			endLine()
+			stripped = true
+		}
+	}
+
+	n := len(src)
+	for i := 0; i < n; {
+		c := src[i]
+		if c == '\n' {
+			continued = i > 0 && src[i-1] == '\\' || i > 1 && src[i-1] == '\r' && src[i-2] == '\\'
+			endLine()
+			i++
+			continue
+		}
+
+		if end, ok := cs.lineComment(src, i); ok {
+			if cs.kept(src[i:]) || (i == 0 && strings.HasPrefix(src, "#!")) {
+				emit(src[i:end])
+			} else {
+				trimmed := strings.TrimRight(cur.String(), " \t")
+				cur.Reset()
+				cur.WriteString(trimmed)
+				stripped = true
+			}
+			i = end
+			continue
+		}
+
+		if open := cs.block[0]; open != "" && strings.HasPrefix(src[i:], open) {
+			end := cs.blockEnd(src, i+len(open))
+			skip(src[i:end])
+			// a block comment separates the tokens around it, like one space
+			prev := cur.String()
+			switch {
+			case prev == "" || isBlank(prev[len(prev)-1]):
+				for end < n && (src[end] == ' ' || src[end] == '\t') {
+					end++
+				}
+			case end < n && !isBlank(src[end]):
+				cur.WriteByte(' ')
+			}
+			i = end
+			continue
+		}
+
+		if end, prefix, ok := cs.stringLiteral(src, i, cur.String()); ok {
+			if cs.python && depth == 0 && !continued && isDocstring(src, end, cur.String(), prefix) {
+				indent := cur.String()
+				indent = indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+				cur.Reset()
+				cur.WriteString(indent)
+				skip(src[i:end])
+			} else {
+				emit(src[i:end])
+			}
+			i = end
+			continue
+		}
+
+		if cs.python {
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth = max(depth-1, 0)
+			}
+		}
+		cur.WriteByte(c)
+		i++
+	}
+	if cur.Len() > 0 || stripped {
+		endLine()
+	}
+	return lines, dropped
+}
+
+// lineComment reports whether a line comment starts at src[i], and where it ends
+// (the following newline or the end of src).
+func (cs *commentSyntax) lineComment(src string, i int) (int, bool) {
+	for _, open := range cs.line {
+		if !strings.HasPrefix(src[i:], open) {
+			continue
+		}
+		if cs.hashWord && i > 0 && !isBlank(src[i-1]) {
+			continue
+		}
+		end := strings.IndexByte(src[i:], '\n')
+		if end < 0 {
+			return len(src), true
+		}
+		return i + end, true
+	}
+	return 0, false
+}
+
+// kept reports whether the comment at the start of s is a directive to keep.
+func (cs *commentSyntax) kept(s string) bool {
+	for _, k := range cs.keep {
+		if strings.HasPrefix(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockEnd returns the index just past the block comment whose body starts at i,
+// or len(src) if it is never closed.
+func (cs *commentSyntax) blockEnd(src string, i int) int {
+	open, close := cs.block[0], cs.block[1]
+	level := 1
+	for i < len(src) {
+		switch {
+		case cs.nested && strings.HasPrefix(src[i:], open):
+			level++
+			i += len(open)
+		case strings.HasPrefix(src[i:], close):
+			level--
+			i += len(close)
+			if level == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(src)
+}
+
+// stringLiteral reports whether a string literal starts at src[i] and returns the
+// index just past it. line is the output of the current line so far; for python,
+// prefix is the string prefix (r, b, f, ...) it ends with.
+func (cs *commentSyntax) stringLiteral(src string, i int, line string) (end int, prefix string, ok bool) {
+	c := src[i]
+	if cs.rust {
+		if c == '\'' {
+			end, ok := rustCharLiteral(src, i)
+			return end, "", ok
+		}
+		if end, ok := rustRawString(src, i); ok {
+			return end, "", true
+		}
+	}
+	escapes := strings.IndexByte(cs.quotes, c) >= 0
+	if !escapes && strings.IndexByte(cs.raw, c) < 0 {
+		return 0, "", false
+	}
+	if cs.scalarQuotes {
+		prev := strings.TrimRight(line, " \t")
+		if prev != "" && !strings.ContainsRune(":-[{,?", rune(prev[len(prev)-1])) {
+			return 0, "", false
+		}
+	}
+	if cs.python {
+		prefix = pythonStringPrefix(line)
+	}
+
+	delim := src[i : i+1]
+	multiline := strings.IndexByte(cs.multiline, c) >= 0
+	if cs.python && (strings.HasPrefix(src[i:], `"""`) || strings.HasPrefix(src[i:], `'''`)) {
+		delim = src[i : i+3]
+		multiline = true
+	}
+	for j := i + len(delim); j < len(src); {
+		switch {
+		case escapes && src[j] == '\\':
+			j += 2
+		case strings.HasPrefix(src[j:], delim):
+			return j + len(delim), prefix, true
+		case src[j] == '\n' && !multiline:
+			// an unterminated string ends at the line break
+			return j, prefix, true
+		default:
+			j++
+		}
+	}
+	return len(src), prefix, true
+}
+
+// isDocstring reports whether the python string ending at end is a statement of
+// its own: only indentation and a string prefix before it on its line, and
+// nothing but a comment after it.
+func isDocstring(src string, end int, line, prefix string) bool {
+	if strings.TrimLeft(line, " \t") != prefix {
+		return false
+	}
+	rest := src[end:]
+	if j := strings.IndexByte(rest, '\n'); j >= 0 {
+		rest = rest[:j]
+	}
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// pythonStringPrefix returns the string prefix (r, b, f, u, rb, ...) that ends
+// line, or "".
+func pythonStringPrefix(line string) string {
+	for _, n := range []int{2, 1} {
+		if len(line) < n {
+			continue
+		}
+		p := line[len(line)-n:]
+		if strings.Trim(strings.ToLower(p), "rbfu") != "" {
+			continue
+		}
+		if len(line) > n && isIdentByte(line[len(line)-n-1]) {
+			continue
+		}
+		return p
+	}
+	return ""
+}
+
+// rustCharLiteral returns the end of the char literal at src[i], reporting false
+// for a lifetime such as 'a.
+func rustCharLiteral(src string, i int) (int, bool) {
+	j := i + 1
+	if j < len(src) && src[j] == '\\' {
+		if k := strings.IndexByte(src[j+1:], '\''); k >= 0 && k < 12 {
+			return j + 1 + k + 1, true
+		}
+		return 0, false
+	}
+	_, size := utf8.DecodeRuneInString(src[j:])
+	if j+size < len(src) && src[j+size] == '\'' {
+		return j + size + 1, true
+	}
+	return 0, false
+}
+
+// rustRawString returns the end of the raw string (r"..." or r#"..."#, optionally
+// b-prefixed) at src[i].
+func rustRawString(src string, i int) (int, bool) {
+	j := i
+	if strings.HasPrefix(src[j:], "br") {
+		j++
+	}
+	if src[j] != 'r' || (i > 0 && isIdentByte(src[i-1])) {
+		return 0, false
+	}
+	j++
+	hashes := 0
+	for j < len(src) && src[j] == '#' {
+		hashes++
+		j++
+	}
+	if j >= len(src) || src[j] != '"' {
+		return 0, false
+	}
+	closing := `"` + strings.Repeat("#", hashes)
+	if k := strings.Index(src[j+1:], closing); k >= 0 {
+		return j + 1 + k + len(closing), true
+	}
+	return len(src), true
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
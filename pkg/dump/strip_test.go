@@ -0,0 +1,92 @@
+package dump
+
+import "testing"
+
+func TestStripComments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		input    string
+		expected string
+	}{
+		{
+			"Go",
+			"main.go",
+			"// Package main.\npackage main\n\n//go:embed data.txt\nvar s = \"// not a comment\" /* inline */ + `/* raw */`\n\n/*\nBlock.\n*/\nfunc f() rune { return '/' } // trailing\n",
+			"package main\n\n//go:embed data.txt\nvar s = \"// not a comment\" + `/* raw */`\n\nfunc f() rune { return '/' }\n",
+		},
+		{
+			"Block comment between tokens",
+			"a.c",
+			"int/**/x = 1; /* a */\n",
+			"int x = 1;\n",
+		},
+		{
+			"JavaScript template literal",
+			"app.ts",
+			"const u = `http://x\n// still a string`; // comment\n",
+			"const u = `http://x\n// still a string`;\n",
+		},
+		{
+			"Python docstrings and hashes in strings",
+			"mod.py",
+			"#!/usr/bin/env python3\n\"\"\"Module\ndocstring.\"\"\"\nURL = \"http://x#y\"  # comment\ndef f():\n    r'''Doc.'''\n    call(\n        \"\"\"argument\"\"\",\n    )\n    return 1 # done\n",
+			"#!/usr/bin/env python3\nURL = \"http://x#y\"\ndef f():\n    call(\n        \"\"\"argument\"\"\",\n    )\n    return 1\n",
+		},
+		{
+			"Rust nested blocks, lifetimes and raw strings",
+			"lib.rs",
+			"/// Doc.\nfn f<'a>(x: &'a str) {\n    let s = r#\"// not\"#; /* a /* b */ c */ let c = '\"';\n}\n",
+			"fn f<'a>(x: &'a str) {\n    let s = r#\"// not\"#; let c = '\"';\n}\n",
+		},
+		{
+			"Shell",
+			"run.sh",
+			"#!/bin/sh\n# comment\necho \"a # b\" 'c # d' $# ${#x} # trailing\n",
+			"#!/bin/sh\necho \"a # b\" 'c # d' $# ${#x}\n",
+		},
+		{
+			"YAML",
+			"config.yaml",
+			"# top\nkey: it's # c\nurl: \"http://x#y\"\nlist:\n  - 'a # b'\n",
+			"key: it's\nurl: \"http://x#y\"\nlist:\n  - 'a # b'\n",
+		},
+		{
+			"Unknown language is unchanged",
+			"notes.txt",
+			"# heading\n// text\n",
+			"# heading\n// text\n",
+		},
+		{
+			"No trailing newline",
+			"a.go",
+			"package a // x",
+			"package a",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := transformString(stripCommentsContent, tc.path, tc.input)
+			if err != nil {
+				t.Fatalf("stripCommentsContent: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("stripCommentsContent(%s) = %q, expected %q", tc.path, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestStripKeepsLineIdentity(t *testing.T) {
+	lines, dropped := goSyntax.strip("a := 1 /* x\ny */ + 2\n// gone\nb := 3\n")
+	expectedLines := []string{"a := 1", "+ 2", "", "b := 3"}
+	expectedDropped := []bool{false, false, true, false}
+	if len(lines) != len(expectedLines) {
+		t.Fatalf("strip returned %q, expected %q", lines, expectedLines)
+	}
+	for i := range lines {
+		if lines[i] != expectedLines[i] || dropped[i] != expectedDropped[i] {
+			t.Errorf("line %d = %q (dropped %v), expected %q (dropped %v)", i+1, lines[i], dropped[i], expectedLines[i], expectedDropped[i])
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TmuxPaneItem struct {
+	ID      string
+	Session string
+	Window  string
+	Pane    string
+	Content string
+}
+
+// TmuxSource captures the scrollback of tmux panes.
+type TmuxSource struct {
+	Selectors []string
+	Lines     int // history lines per pane; 0 = full history
+}
+
+func (s *TmuxSource) Name() string {
+	return "tmux"
+}
+
+func (s *TmuxSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	if env.List {
+		return nil, nil
+	}
+	var wg sync.WaitGroup
+	results, resolveErrs := fetchTmuxConcurrently(s.Selectors, s.Lines, env.Filter, env.Stderr, &wg)
+	// Always surface tmux resolution errors
+	for _, e := range resolveErrs {
+		fmt.Fprintf(env.Stderr, "%v\n", e)
+	}
+	wg.Wait()
+
+	var blocks []*Block
+	for _, item := range results {
+		if item != nil {
+			blocks = append(blocks, &Block{Tmux: item})
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("failed to capture any tmux panes")
+	}
+	return blocks, nil
+}
+
+// runCmd runs a command and returns its trimmed stdout.
+func runCmd(name string, args ...string) (string, error) {
+	return runCmdInput(nil, name, args...)
+}
+
+// runCmdInput is runCmd with stdin read from input.
+func runCmdInput(input io.Reader, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdin = input
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	// clipboard tools like xclip fork a child that keeps stdout open; stop
+	// waiting for it once the command itself has exited
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// resolveTmuxSelectors resolves tmux selectors to a unique list of pane IDs (e.g., %1).
+func resolveTmuxSelectors(selectors []string) ([]string, []error) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return nil, []error{fmt.Errorf("tmux binary not found: %w", err)}
+	}
+
+	seen := make(map[string]struct{})
+	var panes []string
+	var errs []error
+
+	for _, sel := range selectors {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+		var out string
+		var err error
+		switch sel {
+		case "current":
+			out, err = runCmd("tmux", "display-message", "-p", "-F", "#{pane_id}")
+		case "all":
+			// list panes in the current window of the current session (no -a)
+			out, err = runCmd("tmux", "list-panes", "-F", "#{pane_id}")
+		default:
+			// specific target (e.g., %1, 0.1, @uuid)
+			out, err = runCmd("tmux", "display-message", "-p", "-t", sel, "-F", "#{pane_id}")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to resolve selector %q: %v", sel, err))
+			continue
+		}
+		// 'all' may return multiple lines
+		ids := strings.Split(out, "\n")
+		for _, id := range ids {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			panes = append(panes, id)
+		}
+	}
+	return panes, errs
+}
+
+// getPaneMetadata fetches session, window, and pane indexes for a pane id.
+func getPaneMetadata(paneID string) (session, window, pane string, err error) {
+	// Fetch session, window, and pane in a single call (tab-separated)
+	out, err := runCmd("tmux", "display-message", "-p", "-t", paneID, "-F", "#{session_name}\t#{window_index}\t#{pane_index}")
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.Split(out, "\t")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("unexpected tmux metadata format for %s: %q", paneID, out)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// capturePaneContent captures the last N lines (or full history if N==0) from a pane.
+func capturePaneContent(paneID string, lastLines int) (string, error) {
+	args := []string{"capture-pane", "-pJ", "-t", paneID}
+	if lastLines > 0 {
+		args = append(args, "-S", fmt.Sprintf("-%d", lastLines))
+	} else {
+		// full available history
+		args = append(args, "-S", "-", "-E", "-")
+	}
+	out, err := runCmd("tmux", args...)
+	if err != nil {
+		return "", err
+	}
+	return out + "\n", nil // normalize with trailing newline
+}
+
+// fetchTmuxConcurrently captures tmux panes via a worker pool. It returns one slot
+// per resolved pane, in selector order, which is filled once wg is done; panes that
+// could not be captured stay nil.
+func fetchTmuxConcurrently(selectors []string, lines int, filter *regexp.Regexp, stderr io.Writer, wg *sync.WaitGroup) ([]*TmuxPaneItem, []error) {
+	panes, errs := resolveTmuxSelectors(selectors)
+	if len(panes) == 0 {
+		return nil, errs
+	}
+	results := make([]*TmuxPaneItem, len(panes))
+
+	jobs := make(chan int, len(panes))
+	const maxConcurrency = 6
+	workerCount := len(panes)
+	if workerCount > maxConcurrency {
+		workerCount = maxConcurrency
+	}
+
+	// enqueue jobs
+	for i := range panes {
+		jobs <- i
+	}
+	close(jobs)
+
+	// start workers
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				id := panes[i]
+				sess, win, pn, err := getPaneMetadata(id)
+				if err != nil {
+					fmt.Fprintf(stderr, "error getting tmux metadata for %s: %v\n", id, err)
+					continue
+				}
+				content, err := capturePaneContent(id, lines)
+				if err != nil {
+					fmt.Fprintf(stderr, "error capturing tmux pane %s: %v\n", id, err)
+					continue
+				}
+				if filter != nil {
+					content, err = filterContent(strings.NewReader(content), filter)
+					if err != nil {
+						fmt.Fprintf(stderr, "error filtering tmux pane %s: %v\n", id, err)
+						continue
+					}
+				}
+				results[i] = &TmuxPaneItem{
+					ID:      id,
+					Session: sess,
+					Window:  win,
+					Pane:    pn,
+					Content: content,
+				}
+			}
+		}()
+	}
+
+	return results, errs
+}
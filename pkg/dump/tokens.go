@@ -0,0 +1,192 @@
+package dump
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// minTruncateTokens is the smallest remaining budget worth truncating a block into;
+// below this the block is dropped instead.
+const minTruncateTokens = 64
+
+// Tokenizer counts model tokens in a block of text.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// charTokenizer estimates one token per four characters.
+type charTokenizer struct{}
+
+func (charTokenizer) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// bpeTokenizer counts tokens exactly with a tiktoken BPE encoding.
+type bpeTokenizer struct {
+	enc *tiktoken.Tiktoken
+}
+
+func (t bpeTokenizer) Count(text string) int {
+	return len(t.enc.Encode(text, nil, nil))
+}
+
+// NewTokenizer returns the tokenizer for name: cl100k, o200k or chars.
+func NewTokenizer(name string) (Tokenizer, error) {
+	switch name {
+	case "chars":
+		return charTokenizer{}, nil
+	case "cl100k", "o200k":
+		// use the embedded BPE ranks so counting never touches the network
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+		enc, err := tiktoken.GetEncoding(name + "_base")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s encoding: %w", name, err)
+		}
+		return bpeTokenizer{enc: enc}, nil
+	default:
+		return nil, fmt.Errorf("invalid tokenizer %q (must be cl100k, o200k or chars)", name)
+	}
+}
+
+// budgetPriority ranks block kinds for --max-tokens; lower values are kept first.
+var budgetPriority = map[string]int{
+	"tree":    0,
+	"removed": 0,
+	"file":    1,
+	"tmux":    2,
+	"command": 2,
+	"url":     3,
+}
+
+// budgetAction records a block that was dropped or truncated to fit the token budget.
+type budgetAction struct {
+	block  *Block
+	tokens int // tokens before the budget was applied
+	kept   int // tokens after truncation; 0 if the block was dropped
+}
+
+// applyTokenBudget fits blocks into maxTokens and returns the kept blocks in their
+// original order, the token total before the budget was applied, and what was cut.
+//
+// Blocks are admitted by kind priority (trees and removed lists, then files, then
+// tmux panes and commands, then URLs) and in output order within a kind. A block
+// that does not fit is truncated on line boundaries if at least minTruncateTokens
+// remain, otherwise it is dropped; smaller blocks later in the order may still fit.
+func applyTokenBudget(blocks []*Block, maxTokens int, tok Tokenizer, r Renderer) ([]*Block, int, []budgetAction) {
+	counts := make([]int, len(blocks))
+	total := 0
+	for i, b := range blocks {
+		counts[i] = tok.Count(r.RenderBlock(b))
+		total += counts[i]
+	}
+	if total <= maxTokens {
+		return blocks, total, nil
+	}
+
+	order := make([]int, len(blocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return budgetPriority[blocks[order[a]].Kind()] < budgetPriority[blocks[order[b]].Kind()]
+	})
+
+	keep := make([]bool, len(blocks))
+	remaining := maxTokens
+	var actions []budgetAction
+	for _, i := range order {
+		b := blocks[i]
+		if counts[i] <= remaining {
+			keep[i] = true
+			remaining -= counts[i]
+			continue
+		}
+		if remaining >= minTruncateTokens {
+			if n := truncateBlock(b, remaining, tok, r); n > 0 {
+				keep[i] = true
+				remaining -= n
+				actions = append(actions, budgetAction{block: b, tokens: counts[i], kept: n})
+				continue
+			}
+		}
+		actions = append(actions, budgetAction{block: b, tokens: counts[i]})
+	}
+
+	var kept []*Block
+	for i, b := range blocks {
+		if keep[i] {
+			kept = append(kept, b)
+		}
+	}
+	return kept, total, actions
+}
+
+// truncateBlock cuts the block's content on line boundaries so that its rendered form
+// fits in budget tokens. It returns the new token count, or 0 (leaving the block
+// untouched) if the block cannot be truncated or not even one line fits.
+func truncateBlock(b *Block, budget int, tok Tokenizer, r Renderer) int {
+	content := b.body()
+	if content == nil {
+		return 0
+	}
+	original := *content
+	if b.Item != nil {
+		// counted with the attribute that marks the cut, and restored if nothing fits
+		transforms := b.Item.Transforms
+		b.Item.Transforms = appendTransform(transforms, "truncated")
+		defer func() {
+			if *content == original {
+				b.Item.Transforms = transforms
+			}
+		}()
+	}
+	lines := strings.SplitAfter(original, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	marker := func(n int) string {
+		return fmt.Sprintf("... [truncated %d lines to fit --max-tokens] ...\n", n)
+	}
+
+	// estimate how many lines fit from per-line counts, then correct against the real total
+	*content = marker(len(lines))
+	available := budget - tok.Count(r.RenderBlock(b))
+	n := 0
+	for n < len(lines) {
+		c := tok.Count(lines[n])
+		if c > available {
+			break
+		}
+		available -= c
+		n++
+	}
+
+	for ; n > 0; n-- {
+		*content = strings.Join(lines[:n], "") + marker(len(lines)-n)
+		if count := tok.Count(r.RenderBlock(b)); count <= budget {
+			return count
+		}
+	}
+	*content = original
+	return 0
+}
+
+// formatBudgetReport describes what applyTokenBudget cut, for printing on stderr.
+func formatBudgetReport(total, maxTokens int, actions []budgetAction) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "token budget exceeded: %d tokens > --max-tokens %d\n", total, maxTokens)
+	for _, a := range actions {
+		if a.kept > 0 {
+			fmt.Fprintf(&sb, "  truncated %s %s (%d -> %d tokens)\n", a.block.Kind(), a.block.Label(), a.tokens, a.kept)
+		} else {
+			fmt.Fprintf(&sb, "  dropped %s %s (%d tokens)\n", a.block.Kind(), a.block.Label(), a.tokens)
+		}
+	}
+	return sb.String()
+}
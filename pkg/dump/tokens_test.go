@@ -0,0 +1,160 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewTokenizer(t *testing.T) {
+	t.Run("Chars estimate", func(t *testing.T) {
+		tok, err := NewTokenizer("chars")
+		if err != nil {
+			t.Fatalf("NewTokenizer(chars): %v", err)
+		}
+		if got := tok.Count("abcdefgh"); got != 2 {
+			t.Errorf("Count(8 chars) = %d, expected 2", got)
+		}
+		if got := tok.Count("abcde"); got != 2 {
+			t.Errorf("Count(5 chars) = %d, expected 2 (rounded up)", got)
+		}
+	})
+
+	t.Run("cl100k BPE", func(t *testing.T) {
+		tok, err := NewTokenizer("cl100k")
+		if err != nil {
+			t.Fatalf("NewTokenizer(cl100k): %v", err)
+		}
+		if got := tok.Count("hello world"); got != 2 {
+			t.Errorf("Count(%q) = %d, expected 2", "hello world", got)
+		}
+	})
+
+	t.Run("Unknown tokenizer", func(t *testing.T) {
+		if _, err := NewTokenizer("gpt2"); err == nil {
+			t.Error("Expected error for unknown tokenizer, got nil")
+		}
+	})
+}
+
+func TestApplyTokenBudget(t *testing.T) {
+	tok := charTokenizer{}
+	xmlRenderer := textRenderer{format: "xml", tag: "document"}
+	newBlocks := func() []*Block {
+		return []*Block{
+			{Item: &Item{Path: "https://example.com", Content: strings.Repeat("u", 400) + "\n"}},
+			{Item: &Item{Path: "a.go", Content: strings.Repeat("a", 200) + "\n"}},
+			{Tmux: &TmuxPaneItem{ID: "%1", Session: "s", Window: "0", Pane: "0", Content: "pane\n"}},
+			{Item: &Item{Path: "b.go", Content: strings.Repeat("b\n", 400)}},
+		}
+	}
+
+	t.Run("Under budget", func(t *testing.T) {
+		blocks := newBlocks()
+		kept, _, actions := applyTokenBudget(blocks, 100000, tok, xmlRenderer)
+		if len(kept) != len(blocks) || len(actions) != 0 {
+			t.Errorf("Expected all %d blocks kept and no actions, got %d kept, %d actions", len(blocks), len(kept), len(actions))
+		}
+	})
+
+	t.Run("Drops lower priority first", func(t *testing.T) {
+		blocks := newBlocks()
+		budget := 0
+		for _, b := range blocks[1:] {
+			budget += tok.Count(xmlRenderer.RenderBlock(b))
+		}
+		kept, _, actions := applyTokenBudget(blocks, budget, tok, xmlRenderer)
+		if len(kept) != 3 {
+			t.Fatalf("Expected 3 blocks kept, got %d", len(kept))
+		}
+		for _, b := range kept {
+			if b.Kind() == "url" {
+				t.Errorf("Expected URL block to be dropped")
+			}
+		}
+		if len(actions) != 1 || actions[0].kept != 0 || actions[0].block.Label() != "https://example.com" {
+			t.Errorf("Expected a single drop of the URL block, got %+v", actions)
+		}
+	})
+
+	t.Run("Truncates on line boundaries", func(t *testing.T) {
+		blocks := newBlocks()[3:]
+		kept, _, actions := applyTokenBudget(blocks, 100, tok, xmlRenderer)
+		if len(kept) != 1 || len(actions) != 1 || actions[0].kept == 0 {
+			t.Fatalf("Expected b.go to be truncated, got %d kept, actions %+v", len(kept), actions)
+		}
+		if actions[0].kept > 100 {
+			t.Errorf("Truncated block uses %d tokens, expected <= 100", actions[0].kept)
+		}
+		content := kept[0].Item.Content
+		if !strings.Contains(content, "lines to fit --max-tokens] ...\n") {
+			t.Errorf("Expected truncation marker in content, got %q", content)
+		}
+		if !strings.HasPrefix(content, "b\nb\n") {
+			t.Errorf("Expected content to keep leading lines, got %q", content)
+		}
+	})
+}
+
+func TestRunTokenBudgetJSON(t *testing.T) {
+	// quotes, backslashes and tabs double in size when escaped, and json output
+	// indents every field, so the raw content undercounts what is written
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt": strings.Repeat("\t\"q\" \\ \"x\"\t\\\\ \"\"\n", 200),
+		"b.txt": strings.Repeat("\"\\\" line\n", 100),
+	})
+	const maxTokens = 600
+	for _, format := range []string{"json", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Dirs = []string{dir}
+			opts.Format = format
+			opts.MaxTokens = maxTokens
+			opts.Tokenizer = "chars"
+			opts.Stderr = io.Discard
+			var out bytes.Buffer
+			if err := Run(context.Background(), opts, &out); err != nil {
+				t.Fatal(err)
+			}
+			if n := (charTokenizer{}).Count(out.String()); n > maxTokens {
+				t.Errorf("output has %d tokens, expected <= %d", n, maxTokens)
+			}
+			if !strings.Contains(out.String(), "lines to fit --max-tokens] ...") {
+				t.Errorf("expected a truncated file in %s", out.String())
+			}
+			docs := []string{out.String()}
+			if format == "jsonl" {
+				docs = strings.Split(strings.TrimSpace(out.String()), "\n")
+			}
+			for _, doc := range docs {
+				if !json.Valid([]byte(doc)) {
+					t.Errorf("invalid json: %s", doc)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatJSONFrame(t *testing.T) {
+	blocks := []*Block{
+		{Item: &Item{Path: "a.go", Content: "package a\n"}},
+		{Item: &Item{Path: "b.go", Content: "\"b\"\n"}},
+		{Tmux: &TmuxPaneItem{ID: "%1", Content: "pane\n"}},
+		{Removed: &RemovedList{Paths: []string{"c.go", "d.go"}}},
+	}
+	r := jsonRenderer{version: "dev"}
+	// the frame and the records add up to the document, but for the comma
+	// after the last record of each array
+	size := len(r.frame(blocks))
+	for _, b := range blocks {
+		size += len(r.RenderBlock(b))
+	}
+	doc := formatJSONDocument(blocks, "dev")
+	if expected := len(doc) + 3; size != expected {
+		t.Errorf("frame and records = %d bytes, expected %d for\n%s", size, expected, doc)
+	}
+}
@@ -0,0 +1,506 @@
+package dump
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UnpackFile is a file read back from a dump.
+type UnpackFile struct {
+	Path    string
+	Content []byte
+	// Hash and Blob are the hash and blob attributes of the file's document
+	// (--meta): the sha256 and git blob id of the file when it was dumped. Either
+	// is empty when the document has none.
+	Hash string
+	Blob string
+}
+
+// contentHash is the hash attribute of a file with data as its content.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	// xmlOpenTagRgx matches an opening tag with quoted attributes.
+	xmlOpenTagRgx = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_.-]*)((?:\s+[A-Za-z_][A-Za-z0-9_.-]*=(?:'[^']*'|"[^"]*"))*)\s*>`)
+	xmlAttrRgx    = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.-]*)=(?:'([^']*)'|"([^"]*)")`)
+	// mdFenceRgx matches the opening fence of a markdown code block.
+	mdFenceRgx = regexp.MustCompile("^(`{3,})(.*)$")
+)
+
+// xmlAttrUnescaper reverses xmlAttrEscaper.
+var xmlAttrUnescaper = strings.NewReplacer(
+	"&lt;", "<",
+	"&gt;", ">",
+	"&apos;", "'",
+	"&quot;", "\"",
+	"&amp;", "&",
+)
+
+// ParseDump reads the files of a dump in xml, xml-strict or md format, as dump
+// writes them or a model writes them back. Documents of any tag count when they
+// have a path attribute; diff, tree, tmux and command blocks are ignored, as are
+// markdown blocks whose info string does not start with a path (one containing
+// a "/" or "."). Numbered content and binary content other than base64 cannot
+// be read back and are an error.
+func ParseDump(data []byte) ([]UnpackFile, error) {
+	// a file in one format can hold text that looks like the other, so go by
+	// whichever starts first
+	s := string(data)
+	md := firstMarkdownFile(s)
+	if md >= 0 {
+		if xml := firstXMLFile(s); xml < 0 || md < xml {
+			return parseMarkdownDump(s)
+		}
+	}
+	files, err := parseXMLDump(s)
+	if err != nil || len(files) > 0 {
+		return files, err
+	}
+	return parseMarkdownDump(s)
+}
+
+// firstXMLFile returns the offset of the first opening tag with a path in s, or -1.
+func firstXMLFile(s string) int {
+	for _, loc := range xmlOpenTagRgx.FindAllStringSubmatchIndex(s, -1) {
+		if _, ok := parseXMLAttrs(s[loc[4]:loc[5]])["path"]; ok {
+			return loc[0]
+		}
+	}
+	return -1
+}
+
+// firstMarkdownFile returns the offset of the first fence that opens a file's
+// code block in s, or -1.
+func firstMarkdownFile(s string) int {
+	offset := 0
+	for _, line := range strings.SplitAfter(s, "\n") {
+		m := mdFenceRgx.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m != nil {
+			if path, _ := splitMDInfo(m[2]); strings.ContainsAny(path, "/.") {
+				return offset
+			}
+		}
+		offset += len(line)
+	}
+	return -1
+}
+
+func parseXMLDump(s string) ([]UnpackFile, error) {
+	var files []UnpackFile
+	for pos := 0; ; {
+		loc := xmlOpenTagRgx.FindStringSubmatchIndex(s[pos:])
+		if loc == nil {
+			return files, nil
+		}
+		tag := s[pos+loc[2] : pos+loc[3]]
+		attrs := parseXMLAttrs(s[pos+loc[4] : pos+loc[5]])
+		start := pos + loc[1]
+		closeTag := "</" + tag + ">"
+
+		path, isFile := attrs["path"]
+		if !isFile || tag == "diff" {
+			// skip the block so nothing inside it is mistaken for a document
+			if end := strings.Index(s[start:], closeTag); end >= 0 {
+				pos = start + end + len(closeTag)
+			} else {
+				pos = start
+			}
+			continue
+		}
+
+		content := strings.TrimPrefix(s[start:], "\n")
+		var body string
+		if strings.HasPrefix(content, "<![CDATA[") {
+			var rest string
+			var ok bool
+			body, rest, ok = readCDATA(content)
+			rest = strings.TrimPrefix(rest, "\n")
+			if !ok || !strings.HasPrefix(rest, closeTag) {
+				return nil, fmt.Errorf("unterminated <%s path='%s'>", tag, path)
+			}
+			pos = len(s) - len(rest) + len(closeTag)
+		} else {
+			end := strings.Index(content, closeTag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated <%s path='%s'>", tag, path)
+			}
+			body = content[:end]
+			pos = len(s) - len(content) + end + len(closeTag)
+			// a body that holds the tag itself cannot be told apart from the
+			// document around it, and would be cut short
+			if holdsOpenTag(body, tag) || strayCloseTag(s[pos:], tag) {
+				return nil, fmt.Errorf("ambiguous <%s path='%s'>: its content holds a <%s> tag of its own (dump it with -o xml-strict)", tag, path, tag)
+			}
+		}
+
+		file, err := unpackFile(path, body, attrs)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+}
+
+// holdsOpenTag reports whether s has an opening tag named tag with a path.
+func holdsOpenTag(s, tag string) bool {
+	for _, m := range xmlOpenTagRgx.FindAllStringSubmatch(s, -1) {
+		if _, ok := parseXMLAttrs(m[2])["path"]; ok && m[1] == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// strayCloseTag reports whether rest, which follows a document, closes a tag
+// named tag before opening another one: the document's real end, past a closing
+// tag in its content.
+func strayCloseTag(rest, tag string) bool {
+	end := strings.Index(rest, "</"+tag+">")
+	if end < 0 {
+		return false
+	}
+	for _, loc := range xmlOpenTagRgx.FindAllStringSubmatchIndex(rest[:end], -1) {
+		if rest[loc[2]:loc[3]] == tag {
+			return false
+		}
+	}
+	return true
+}
+
+func parseXMLAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range xmlAttrRgx.FindAllStringSubmatch(s, -1) {
+		value := m[2]
+		if value == "" {
+			value = m[3]
+		}
+		attrs[m[1]] = xmlAttrUnescaper.Replace(value)
+	}
+	return attrs
+}
+
+// readCDATA reads consecutive CDATA sections from the start of s, which cdata
+// writes to split a "]]>", and returns their joined text and what follows.
+func readCDATA(s string) (text, rest string, ok bool) {
+	var sb strings.Builder
+	for strings.HasPrefix(s, "<![CDATA[") {
+		s = s[len("<![CDATA["):]
+		end := strings.Index(s, "]]>")
+		if end < 0 {
+			return "", "", false
+		}
+		sb.WriteString(s[:end])
+		s = s[end+len("]]>"):]
+		ok = true
+	}
+	return sb.String(), s, ok
+}
+
+// splitMDInfo splits the info string of a code block into the path, which dump
+// quotes when it holds spaces (mdInfoPath), and the attributes after it.
+func splitMDInfo(info string) (path string, attrs []string) {
+	info = strings.TrimSpace(info)
+	if strings.HasPrefix(info, `"`) {
+		if quoted, err := strconv.QuotedPrefix(info); err == nil {
+			if path, err := strconv.Unquote(quoted); err == nil {
+				return path, strings.Fields(info[len(quoted):])
+			}
+		}
+	}
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+func parseMarkdownDump(s string) ([]UnpackFile, error) {
+	var files []UnpackFile
+	lines := strings.SplitAfter(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := mdFenceRgx.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			continue
+		}
+		fence := m[1]
+		path, fields := splitMDInfo(m[2])
+
+		// find the closing fence, at least as long as the opening one
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			line := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(line, fence) && strings.Trim(line, "`") == "" {
+				end = j
+				break
+			}
+		}
+		if !strings.ContainsAny(path, "/.") {
+			// a code block that is not a file
+			if end < 0 {
+				return files, nil
+			}
+			i = end
+			continue
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated code block for %s", path)
+		}
+		// dump fences a block with more backticks than its content holds, so a
+		// line that opens another such fence means the block ends somewhere else
+		for _, line := range lines[i+1 : end] {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				return nil, fmt.Errorf("ambiguous code block for %s: its content opens a %s fence of its own", path, fence)
+			}
+		}
+
+		// flags such as line_numbers are bare words
+		attrs := make(map[string]string)
+		for _, f := range fields {
+			name, value, ok := strings.Cut(f, "=")
+			if !ok {
+				value = "true"
+			}
+			attrs[name] = value
+		}
+		file, err := unpackFile(path, strings.Join(lines[i+1:end], ""), attrs)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		i = end
+	}
+	return files, nil
+}
+
+// transformedAttrs are the attributes of documents whose content differs from
+// the file, and how to dump the file as it is.
+var transformedAttrs = []struct{ name, hint string }{
+	{"line_numbers", "numbered content (dump it without --line-numbers)"},
+	{"outline", "outlined content (dump it without --outline)"},
+	{"stripped", "content with comments stripped (dump it without --strip-comments)"},
+	{"filtered", "filtered content (dump it without --filter and --contains)"},
+	{"squeezed", "content with blank lines squeezed (dump it without --squeeze-blank)"},
+	{"truncated", "truncated content (dump it without --max-lines, --head, --tail and --max-tokens)"},
+	{"redacted", "redacted content (dump it with --redact=off)"},
+}
+
+// unpackFile decodes the content of a document with the given attributes.
+func unpackFile(path, body string, attrs map[string]string) (UnpackFile, error) {
+	for _, a := range transformedAttrs {
+		if attrs[a.name] == "true" {
+			return UnpackFile{}, fmt.Errorf("%s: cannot unpack %s", path, a.hint)
+		}
+	}
+	content := []byte(body)
+	switch mode := attrs["binary"]; mode {
+	case "":
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+		if err != nil {
+			return UnpackFile{}, fmt.Errorf("%s: invalid base64 content: %w", path, err)
+		}
+		content = data
+	default:
+		return UnpackFile{}, fmt.Errorf("%s: cannot unpack binary=%s content (dump it with --include-binary=base64)", path, mode)
+	}
+	return UnpackFile{Path: path, Content: content, Hash: attrs["hash"], Blob: attrs["blob"]}, nil
+}
+
+// UnpackOptions configure Unpack.
+type UnpackOptions struct {
+	// Root is the directory files are written beneath (default: the working
+	// directory). Paths that are absolute, contain "..", or lead out of Root
+	// through a symlink are refused.
+	Root string
+	// Strip removes this many leading components from each path, like the -p
+	// of patch: dump names files after the directory it walked, so unpacking
+	// into that directory takes Strip 1.
+	Strip int
+	// DryRun writes a unified diff of the changes to the Unpack writer instead
+	// of changing any file.
+	DryRun bool
+	// Force overwrites files that changed since they were dumped, as told by
+	// their hash or blob attribute.
+	Force bool
+	// Stderr receives the summary and the list of refused files.
+	Stderr io.Writer
+}
+
+// unpackTarget is a file of an unpack with where it goes and what is there.
+type unpackTarget struct {
+	file   UnpackFile
+	rel    string // slash path beneath the root
+	path   string
+	old    []byte
+	exists bool
+}
+
+// Unpack writes files beneath opts.Root, or previews them as a diff on w with
+// DryRun. Every file is checked first, and if any is refused, nothing is written.
+func Unpack(files []UnpackFile, opts UnpackOptions, w io.Writer) error {
+	stderr := opts.Stderr
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if opts.Strip < 0 {
+		return fmt.Errorf("invalid --strip %d (must be >= 0)", opts.Strip)
+	}
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	roots, err := resolveRoots([]string{root})
+	if err != nil {
+		return err
+	}
+
+	var targets []*unpackTarget
+	var refused skipList
+	seen := make(map[string]bool)
+	for _, f := range files {
+		t, err := planUnpack(f, roots, opts.Strip)
+		if err != nil {
+			refused.add(f.Path, err.Error())
+			continue
+		}
+		if seen[t.rel] {
+			refused.add(f.Path, "appears more than once")
+			continue
+		}
+		seen[t.rel] = true
+		if (f.Hash != "" || f.Blob != "") && !opts.Force {
+			switch {
+			case !t.exists:
+				refused.add(f.Path, "deleted since it was dumped (--force writes it anyway)")
+				continue
+			case f.Hash != "" && !strings.EqualFold(contentHash(t.old), f.Hash),
+				f.Hash == "" && !strings.EqualFold(gitBlobID(t.old), f.Blob):
+				refused.add(f.Path, "changed since it was dumped (--force overwrites it)")
+				continue
+			}
+		}
+		targets = append(targets, t)
+	}
+	if problems := refused.sorted(); len(problems) > 0 {
+		fmt.Fprint(stderr, formatUnpackReport(problems))
+		return fmt.Errorf("refusing to unpack: %d of %d files failed the checks", len(problems), len(files))
+	}
+
+	var created, updated, unchanged int
+	for _, t := range targets {
+		switch {
+		case !t.exists:
+			created++
+		case bytes.Equal(t.old, t.file.Content):
+			unchanged++
+			continue
+		default:
+			updated++
+		}
+		if opts.DryRun {
+			oldName := "a/" + t.rel
+			if !t.exists {
+				oldName = "/dev/null"
+			}
+			if _, err := io.WriteString(w, unifiedDiff(oldName, "b/"+t.rel, string(t.old), string(t.file.Content))); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", t.rel, err)
+		}
+		if err := writeFileAtomic(t.path, t.file.Content); err != nil {
+			return err
+		}
+	}
+
+	verb, noun := "unpacked", "files"
+	if opts.DryRun {
+		verb = "would unpack"
+	}
+	if len(targets) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(stderr, "%s %d %s into %s: %d created, %d updated, %d unchanged\n",
+		verb, len(targets), noun, root, created, updated, unchanged)
+	return nil
+}
+
+// planUnpack checks the path of f and finds where it goes beneath the root.
+func planUnpack(f UnpackFile, roots []string, strip int) (*unpackTarget, error) {
+	p := f.Path
+	if p == "" {
+		return nil, errors.New("empty path")
+	}
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "/") || strings.HasPrefix(p, `\`) || filepath.VolumeName(p) != "" {
+		return nil, errors.New("absolute path")
+	}
+	var parts []string
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		switch part {
+		case "..":
+			return nil, errors.New("path leaves the root (..)")
+		case ".":
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) <= strip {
+		return nil, fmt.Errorf("fewer than %d components to strip", strip+1)
+	}
+	rel := strings.Join(parts[strip:], "/")
+	t := &unpackTarget{file: f, rel: rel, path: filepath.Join(roots[0], filepath.FromSlash(rel))}
+
+	// the closest existing ancestor must not lead out of the root
+	existing := t.path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	if !withinRoots(roots, existing) {
+		return nil, errors.New("path leaves the root through a symlink")
+	}
+	if existing == t.path {
+		info, err := os.Stat(t.path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, errors.New("not a regular file")
+		}
+		if t.old, err = os.ReadFile(t.path); err != nil {
+			return nil, err
+		}
+		t.exists = true
+	}
+	return t, nil
+}
+
+func formatUnpackReport(files []skippedFile) string {
+	var sb strings.Builder
+	noun := "files"
+	if len(files) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(&sb, "refused %d %s:\n", len(files), noun)
+	for _, f := range files {
+		fmt.Fprintf(&sb, "  %s: %s\n", f.path, f.reason)
+	}
+	return sb.String()
+}
@@ -0,0 +1,171 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const exaBaseURL = "https://api.exa.ai/contents"
+
+type ExaRequest struct {
+	URLs      []string `json:"urls"`
+	Text      bool     `json:"text"`
+	Context   bool     `json:"context"`
+	Livecrawl string   `json:"livecrawl"`
+}
+
+type ExaResult struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type ExaResponse struct {
+	Results []ExaResult `json:"results"`
+	Context string      `json:"context"`
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// URLSource fetches page contents via the Exa API.
+type URLSource struct {
+	URLs      []string
+	APIKey    string
+	LiveCrawl bool
+	Timeout   time.Duration
+}
+
+func (s *URLSource) Name() string {
+	return "urls"
+}
+
+func (s *URLSource) Collect(ctx context.Context, env *Env) ([]*Block, error) {
+	if env.List {
+		return nil, nil
+	}
+	results := make([]*Item, len(s.URLs))
+	var wg sync.WaitGroup
+	fetchURLsConcurrently(ctx, s.URLs, s.APIKey, s.LiveCrawl, s.Timeout, env.Stderr, &wg, results)
+	wg.Wait()
+
+	var blocks []*Block
+	for _, item := range results {
+		if item != nil {
+			blocks = append(blocks, &Block{Item: item})
+		}
+	}
+	return blocks, nil
+}
+
+// fetchURLsConcurrently fetches urls via a rate-limited worker pool. results[i]
+// receives the content of urls[i] and stays nil if the fetch failed.
+func fetchURLsConcurrently(
+	ctx context.Context, urls []string, apiKey string, liveCrawl bool, timeout time.Duration,
+	stderr io.Writer, wg *sync.WaitGroup, results []*Item,
+) {
+	if len(urls) == 0 {
+		return
+	}
+
+	const maxConcurrency = 3
+	const rateLimitDelay = 350 * time.Millisecond // ~3 requests per second (350ms * 3 = ~1050ms)
+
+	urlsChan := make(chan int, len(urls))
+
+	// Send URL indexes to channel
+	for i := range urls {
+		urlsChan <- i
+	}
+	close(urlsChan)
+
+	// start worker goroutines
+	for i := 0; i < maxConcurrency; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for i := range urlsChan {
+				url := urls[i]
+				// rate limiting: stagger requests
+				time.Sleep(time.Duration(workerID) * rateLimitDelay / maxConcurrency)
+
+				result, err := fetchURLContent(ctx, url, apiKey, liveCrawl, timeout)
+				if err != nil {
+					fmt.Fprintf(stderr, "error fetching URL %s: %v\n", url, err)
+					continue
+				}
+				results[i] = result
+
+				// Add delay between requests from same worker
+				time.Sleep(rateLimitDelay)
+			}
+		}(i)
+	}
+}
+
+func fetchURLContent(ctx context.Context, targetURL string, apiKey string, liveCrawl bool, timeout time.Duration) (*Item, error) {
+	u, err := url.ParseRequestURI(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("URL must use HTTP or HTTPS scheme")
+	}
+
+	reqBody := ExaRequest{
+		URLs:      []string{targetURL},
+		Text:      true,
+		Context:   true,
+		Livecrawl: "fallback",
+	}
+	if liveCrawl {
+		reqBody.Livecrawl = "always"
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", exaBaseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status: %s", resp.Status)
+	}
+
+	var exaResp ExaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&exaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(strings.TrimSpace(exaResp.Context)) == 0 {
+		return nil, fmt.Errorf("no context field in response")
+	}
+
+	return &Item{
+		Path:    targetURL,
+		Content: exaResp.Context,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kabilan108/dump/pkg/dump"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr  string
+	serveToken string
+	serveRoots []string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve dumps over a local HTTP API",
+	Long: `serve dumps over HTTP so editor plugins and browser extensions can request them without spawning
+processes: POST /dump (an options JSON body; returns xml, md or json), GET /list and GET /tree (options as
+query parameters). every request needs the bearer token and may only read below the --root directories.`,
+	Example: `  dump serve                                  serve the current directory on 127.0.0.1:7777
+  dump serve --root ~/src --root ~/notes      allow two directory trees
+  curl -H "Authorization: Bearer $DUMP_TOKEN" -d '{"dir":["pkg"],"out_fmt":"md"}' localhost:7777/dump`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := serverOptions()
+		if err != nil {
+			return err
+		}
+		token := serveToken
+		if token == "" {
+			token = os.Getenv("DUMP_TOKEN")
+		}
+		if token == "" {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				return fmt.Errorf("failed to generate a token: %w", err)
+			}
+			token = hex.EncodeToString(b)
+			fmt.Fprintf(os.Stderr, "token: %s\n", token)
+		}
+		roots := serveRoots
+		if len(roots) == 0 {
+			roots = []string{"."}
+		}
+		handler, err := dump.NewHandler(base, dump.ServerConfig{Token: token, Roots: roots})
+		if err != nil {
+			return err
+		}
+
+		ln, err := net.Listen("tcp", serveAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", serveAddr, err)
+		}
+		fmt.Fprintf(os.Stderr, "serving on http://%s\n", ln.Addr())
+		srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-cmd.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(ctx)
+		}()
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:7777", "address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "bearer token requests must carry (default: $DUMP_TOKEN, or a random token printed on start)")
+	serveCmd.Flags().StringArrayVar(&serveRoots, "root", nil, "directory requests may read, can be repeated (default: the current directory)")
+	rootCmd.AddCommand(serveCmd)
+}
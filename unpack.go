@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kabilan108/dump/pkg/dump"
+	"github.com/spf13/cobra"
+)
+
+var unpackOpts dump.UnpackOptions
+
+var unpackCmd = &cobra.Command{
+	Use:   "unpack [file]",
+	Short: "Write the files of a dump (or a model's reply in the same format) to disk",
+	Long: `read documents in dump's xml, xml-strict or md format from file or stdin and write them beneath --root.
+paths that are absolute, contain "..", or lead out of the root through a symlink are refused, as are files
+that changed since they were dumped when the document carries a hash attribute. nothing is written unless
+every file passes these checks.`,
+	Example: `  dump unpack --dry-run < reply.xml          preview the changes as a unified diff
+  dump unpack --strip 1 < reply.xml          write myproject/main.go as ./main.go
+  dump unpack --root ../copy reply.md        write beneath ../copy`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var r io.Reader = os.Stdin
+		if len(args) == 1 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", args[0], err)
+			}
+			defer f.Close()
+			r = f
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read the dump: %w", err)
+		}
+		files, err := dump.ParseDump(data)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no files found in the dump")
+		}
+		opts := unpackOpts
+		opts.Stderr = os.Stderr
+		return dump.Unpack(files, opts, os.Stdout)
+	},
+}
+
+func init() {
+	unpackCmd.Flags().BoolVarP(&unpackOpts.DryRun, "dry-run", "n", false, "print a diff of the changes instead of writing them")
+	unpackCmd.Flags().StringVar(&unpackOpts.Root, "root", ".", "directory to write the files beneath")
+	unpackCmd.Flags().IntVar(&unpackOpts.Strip, "strip", 0, "leading path components to remove, like patch -p")
+	unpackCmd.Flags().BoolVar(&unpackOpts.Force, "force", false, "overwrite files that changed since they were dumped")
+	rootCmd.AddCommand(unpackCmd)
+}